@@ -0,0 +1,407 @@
+package fluxdb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saivenkatram-git/fluxdb/config/resp"
+)
+
+// AOF PERSISTENCE --------------------------------------------------------------------------------------------------
+//
+// FluxDB can log every mutating command to an append-only file so that its
+// keyspace survives a restart. The file holds RESP-encoded command arrays,
+// one per mutation, in the same wire format clients use to issue them; on
+// startup New() replays the file back through processCommand with a
+// discarding connection to rebuild state. Sync behaviour is controlled by
+// the appendfsync config key: "always" fsyncs after every write, "everysec"
+// fsyncs from a background goroutine once a second, and "no" leaves syncing
+// to the OS.
+
+// aof holds the open append-only file and the state needed to flush,
+// rewrite and sync it.
+type aof struct {
+	mu       sync.Mutex
+	file     *os.File
+	path     string
+	dirtyMu  sync.Mutex
+	dirty    bool
+	stopSync chan struct{}
+
+	// lastDB is the database a SELECT was last written for, so append only
+	// emits a SELECT frame when the target database actually changes.
+	lastDB int
+}
+
+// aofDefaults seeds the config keys this subsystem reads, matching the
+// pattern used for the server's other CLI defaults in New().
+func aofDefaults(config map[string]string) {
+	config["appendonly"] = "no"
+	config["appendfilename"] = "appendonly.aof"
+	config["appendfsync"] = "everysec"
+	config["dir"] = "."
+}
+
+// aofPath resolves the configured AOF file relative to the configured dir.
+func (f *FluxDB) aofPath() string {
+	return filepath.Join(f.GetConfig("dir"), f.GetConfig("appendfilename"))
+}
+
+// openAOF opens path for appending and, if appendfsync is "everysec",
+// starts the background goroutine that fsyncs it once a second.
+func (f *FluxDB) openAOF(path string) (*aof, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &aof{file: file, path: path, stopSync: make(chan struct{}), lastDB: -1}
+	if f.GetConfig("appendfsync") == "everysec" {
+		go a.runEverysecSync()
+	}
+	return a, nil
+}
+
+// setupAOF does nothing unless appendonly is enabled; when it is, it replays
+// the existing AOF file (if any) and opens it for further appends. Called
+// once from New().
+func (f *FluxDB) setupAOF() {
+	if f.GetConfig("appendonly") != "yes" {
+		return
+	}
+
+	path := f.aofPath()
+
+	if err := f.replayAOF(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("AOF: replay of %s failed: %v", path, err)
+	}
+
+	a, err := f.openAOF(path)
+	if err != nil {
+		log.Printf("AOF: could not open %s for append: %v", path, err)
+		return
+	}
+	f.aof = a
+}
+
+// toggleAOF enables or disables persistence in response to a CONFIG SET
+// appendonly call, so the feature can be turned on (or off) at runtime
+// rather than only by the appendonly default New() was started with.
+// Swapping f.aof happens under aofMu's write lock so a concurrent
+// appendIfMutating/rewrite reading it via getAOF never sees a torn update.
+func (f *FluxDB) toggleAOF(value string) {
+	switch value {
+	case "yes":
+		f.aofMu.Lock()
+		if f.aof != nil {
+			f.aofMu.Unlock()
+			return
+		}
+		a, err := f.openAOF(f.aofPath())
+		if err != nil {
+			f.aofMu.Unlock()
+			log.Printf("AOF: could not open %s for append: %v", f.aofPath(), err)
+			return
+		}
+		f.aof = a
+		f.aofMu.Unlock()
+
+		// Enabling persistence mid-session snapshots the live keyspace into
+		// the file instead of replaying it: unlike the New()/setupAOF
+		// startup path, memory here already reflects everything that
+		// happened before now, so replaying would duplicate it.
+		if err := f.rewrite(); err != nil {
+			log.Printf("AOF: initial snapshot of %s failed: %v", a.path, err)
+		}
+
+	case "no":
+		f.aofMu.Lock()
+		a := f.aof
+		f.aof = nil
+		f.aofMu.Unlock()
+
+		if a == nil {
+			return
+		}
+		a.mu.Lock()
+		close(a.stopSync)
+		a.file.Close()
+		a.mu.Unlock()
+	}
+}
+
+// getAOF returns the current *aof, or nil if persistence isn't enabled,
+// under aofMu's read lock so it can never observe a half-completed toggle.
+func (f *FluxDB) getAOF() *aof {
+	f.aofMu.RLock()
+	defer f.aofMu.RUnlock()
+	return f.aof
+}
+
+// replayAOF feeds every command in the file at path back through
+// processCommand against a connection that discards all output, rebuilding
+// the in-memory keyspace exactly as it was when the file was last written.
+func (f *FluxDB) replayAOF(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	f.loading = true
+	defer func() { f.loading = false }()
+
+	c := newClient(io.Discard)
+	w := resp.NewWriter(io.Discard)
+	c.writer = w
+	reader := resp.NewReader(file)
+
+	replayed := 0
+	for {
+		command, err := reader.ReadCommand()
+		if err != nil {
+			if err != io.EOF {
+				return err
+			}
+			break
+		}
+		if len(command.Args) == 0 {
+			continue
+		}
+		f.processCommand(toStrings(command.Args), c, w)
+		replayed++
+	}
+
+	log.Printf("AOF: replayed %d commands from %s", replayed, path)
+	return nil
+}
+
+// appendIfMutating logs cmd, issued against logical database db, to the AOF
+// when persistence is enabled and cmd is a command that changes the
+// keyspace. It is a no-op during replay.
+//
+// Callers in db.go/hash.go/list.go/set.go/zset.go/expire.go call this while
+// still holding the keyspace lock their mutation was made under, so that
+// two concurrent writers to the same key can never apply to memory in one
+// order but log to the AOF in the other: the mutation and its append happen
+// in the same critical section. FLUSHALL is the one exception: FlushAll
+// touches every keyspace's lock in turn rather than holding a single lock
+// for the whole operation, and the FLUSHALL handler in registry.go logs the
+// command after FlushAll has returned (see FlushAll's doc comment in db.go).
+func (f *FluxDB) appendIfMutating(db int, cmd []string) {
+	if f.loading || len(cmd) == 0 {
+		return
+	}
+	a := f.getAOF()
+	if a == nil {
+		return
+	}
+
+	switch strings.ToUpper(cmd[0]) {
+	case "SET", "DEL", "EXPIRE", "PERSIST",
+		"HSET", "HDEL",
+		"LPUSH", "RPUSH", "LPOP", "RPOP",
+		"SADD", "SREM",
+		"ZADD",
+		"FLUSHDB", "FLUSHALL", "SWAPDB", "MOVE":
+		a.append(db, cmd, f.GetConfig("appendfsync"))
+	}
+}
+
+// append encodes cmd as a RESP command array and writes it to the AOF,
+// preceded by a SELECT frame if db differs from the last command written,
+// and fsyncing immediately when fsyncMode is "always".
+func (a *aof) append(db int, cmd []string, fsyncMode string) {
+	a.mu.Lock()
+
+	var buf bytes.Buffer
+	w := resp.NewWriter(&buf)
+
+	if db != a.lastDB {
+		w.WriteArray(2)
+		w.WriteBulk([]byte("SELECT"))
+		w.WriteBulk([]byte(strconv.Itoa(db)))
+		a.lastDB = db
+	}
+
+	w.WriteArray(len(cmd))
+	for _, arg := range cmd {
+		w.WriteBulk([]byte(arg))
+	}
+	w.Flush()
+
+	_, err := a.file.Write(buf.Bytes())
+	if err == nil && fsyncMode == "always" {
+		err = a.file.Sync()
+	}
+	a.mu.Unlock()
+
+	if err != nil {
+		log.Printf("AOF: write to %s failed: %v", a.path, err)
+		return
+	}
+
+	a.dirtyMu.Lock()
+	a.dirty = true
+	a.dirtyMu.Unlock()
+}
+
+// runEverysecSync fsyncs the AOF once a second while there have been writes
+// since the last sync, so a crash loses at most ~1s of mutations.
+func (a *aof) runEverysecSync() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.dirtyMu.Lock()
+			dirty := a.dirty
+			a.dirty = false
+			a.dirtyMu.Unlock()
+
+			if dirty {
+				a.mu.Lock()
+				a.file.Sync()
+				a.mu.Unlock()
+			}
+		case <-a.stopSync:
+			return
+		}
+	}
+}
+
+// writeRewriteEntry emits the command(s) needed to recreate e at key,
+// followed by an EXPIRE if it carries a TTL. Called with the entry's
+// keyspace lock held for reading.
+func writeRewriteEntry(w *resp.Writer, key string, e *entry) {
+	switch e.typ {
+	case typeString:
+		w.WriteArray(3)
+		w.WriteBulk([]byte("SET"))
+		w.WriteBulk([]byte(key))
+		w.WriteBulk([]byte(e.str))
+
+	case typeHash:
+		w.WriteArray(2 + 2*len(e.hash))
+		w.WriteBulk([]byte("HSET"))
+		w.WriteBulk([]byte(key))
+		for field, value := range e.hash {
+			w.WriteBulk([]byte(field))
+			w.WriteBulk([]byte(value))
+		}
+
+	case typeList:
+		w.WriteArray(2 + len(e.list))
+		w.WriteBulk([]byte("RPUSH"))
+		w.WriteBulk([]byte(key))
+		for _, v := range e.list {
+			w.WriteBulk([]byte(v))
+		}
+
+	case typeSet:
+		w.WriteArray(2 + len(e.set))
+		w.WriteBulk([]byte("SADD"))
+		w.WriteBulk([]byte(key))
+		for m := range e.set {
+			w.WriteBulk([]byte(m))
+		}
+
+	case typeZSet:
+		w.WriteArray(2 + 2*len(e.zset.dict))
+		w.WriteBulk([]byte("ZADD"))
+		w.WriteBulk([]byte(key))
+		for member, score := range e.zset.dict {
+			w.WriteBulk([]byte(strconv.FormatFloat(score, 'g', -1, 64)))
+			w.WriteBulk([]byte(member))
+		}
+	}
+
+	if !e.expireAt.IsZero() {
+		w.WriteArray(3)
+		w.WriteBulk([]byte("EXPIRE"))
+		w.WriteBulk([]byte(key))
+		w.WriteBulk([]byte(strconv.Itoa(int(time.Until(e.expireAt).Seconds()))))
+	}
+}
+
+// rewrite compacts the AOF by writing the current keyspace out as a fresh
+// series of commands recreating every key to a temp file and atomically
+// swapping it in for the live log, matching Redis's AOF REWRITE /
+// BGREWRITEAOF.
+func (f *FluxDB) rewrite() error {
+	a := f.getAOF()
+	if a == nil {
+		return fmt.Errorf("AOF is not enabled")
+	}
+
+	tmpPath := a.path + ".rewrite"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	w := resp.NewWriter(&buf)
+
+	f.dbsMu.RLock()
+	for i, ks := range f.dbs {
+		ks.mu.RLock()
+		if len(ks.data) == 0 {
+			ks.mu.RUnlock()
+			continue
+		}
+
+		w.WriteArray(2)
+		w.WriteBulk([]byte("SELECT"))
+		w.WriteBulk([]byte(strconv.Itoa(i)))
+
+		for key, e := range ks.data {
+			if e.expired() {
+				continue
+			}
+			writeRewriteEntry(w, key, e)
+		}
+		ks.mu.RUnlock()
+	}
+	f.dbsMu.RUnlock()
+
+	w.Flush()
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.Rename(tmpPath, a.path); err != nil {
+		return err
+	}
+
+	a.file.Close()
+	file, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	a.file = file
+	a.lastDB = -1
+
+	return nil
+}
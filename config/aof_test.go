@@ -0,0 +1,177 @@
+package fluxdb
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// TestAOFAppendAndReplay writes a handful of mutations to the AOF and
+// verifies replaying it into a fresh FluxDB rebuilds the same keyspace.
+func TestAOFAppendAndReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	f := New(WithDatabases(2))
+	f.SetConfig("dir", dir)
+	f.SetConfig("appendfilename", "test.aof")
+	f.SetConfig("appendfsync", "always")
+	f.SetConfig("appendonly", "yes")
+
+	if f.aof == nil {
+		t.Fatal("CONFIG SET appendonly yes did not enable AOF")
+	}
+
+	f.Set(0, "greeting", "hello")
+	f.Set(1, "counter", "1")
+
+	replayed := New(WithDatabases(2))
+	if err := replayed.replayAOF(filepath.Join(dir, "test.aof")); err != nil {
+		t.Fatalf("replayAOF: %v", err)
+	}
+
+	if val, ok, err := replayed.Get(0, "greeting"); err != nil || !ok || val != "hello" {
+		t.Fatalf("Get(0, greeting) = %q, %v, %v; want hello, true, nil", val, ok, err)
+	}
+	if val, ok, err := replayed.Get(1, "counter"); err != nil || !ok || val != "1" {
+		t.Fatalf("Get(1, counter) = %q, %v, %v; want 1, true, nil", val, ok, err)
+	}
+}
+
+// TestAOFAppendSkipsRedundantSelect verifies append only emits a SELECT
+// frame when the target database actually changes between writes.
+func TestAOFAppendSkipsRedundantSelect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "select.aof")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &aof{file: file, path: path, lastDB: -1}
+
+	a.append(0, []string{"SET", "a", "1"}, "no")
+	a.append(0, []string{"SET", "b", "2"}, "no")
+	file.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bytes.Count(data, []byte("SELECT")); got != 1 {
+		t.Fatalf("SELECT frames = %d, want 1 for two commands against the same db", got)
+	}
+}
+
+// TestAOFAppendConcurrentIsRaceFree exercises append from many goroutines at
+// once, the pattern every connection's SET/DEL/... hits in real use; run
+// with -race to catch lastDB being read/written outside a.mu.
+func TestAOFAppendConcurrentIsRaceFree(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "concurrent.aof")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	a := &aof{file: file, path: path, lastDB: -1}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(db int) {
+			defer wg.Done()
+			a.append(db%2, []string{"SET", "k", "v"}, "no")
+		}(i)
+	}
+	wg.Wait()
+}
+
+// TestAOFAppendOrderMatchesMutationOrder hammers the same key from many
+// goroutines with AOF enabled and checks that replaying the log lands on
+// the same final value the live keyspace settled on. Set logs its AOF entry
+// while still holding the key's keyspace lock, so whichever writer's
+// mutation is applied last is also the one logged last; if the append ever
+// moved outside that critical section, the two could disagree.
+func TestAOFAppendOrderMatchesMutationOrder(t *testing.T) {
+	dir := t.TempDir()
+
+	f := New(WithDatabases(1))
+	f.SetConfig("dir", dir)
+	f.SetConfig("appendfilename", "order.aof")
+	f.SetConfig("appendonly", "yes")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			f.Set(0, "k", strconv.Itoa(i))
+		}(i)
+	}
+	wg.Wait()
+
+	want, _, err := f.Get(0, "k")
+	if err != nil {
+		t.Fatalf("Get(0, k): %v", err)
+	}
+
+	replayed := New(WithDatabases(1))
+	if err := replayed.replayAOF(filepath.Join(dir, "order.aof")); err != nil {
+		t.Fatalf("replayAOF: %v", err)
+	}
+	got, _, err := replayed.Get(0, "k")
+	if err != nil {
+		t.Fatalf("replayed Get(0, k): %v", err)
+	}
+
+	if got != want {
+		t.Fatalf("replayed value = %q, want %q (live keyspace's final value)", got, want)
+	}
+}
+
+// TestToggleAOFReenableDoesNotDuplicate verifies that disabling and
+// re-enabling AOF at runtime snapshots the live keyspace instead of
+// replaying the old file, which would double-apply already-live mutations.
+func TestToggleAOFReenableDoesNotDuplicate(t *testing.T) {
+	dir := t.TempDir()
+
+	f := New(WithDatabases(1))
+	f.SetConfig("dir", dir)
+	f.SetConfig("appendfilename", "toggle.aof")
+	f.SetConfig("appendonly", "yes")
+
+	f.LPush(0, "list", []string{"v"})
+
+	f.SetConfig("appendonly", "no")
+	f.SetConfig("appendonly", "yes")
+
+	if n, err := f.LLen(0, "list"); err != nil || n != 1 {
+		t.Fatalf("LLen after disable/re-enable = %d, %v; want 1, nil (re-enable must not replay)", n, err)
+	}
+}
+
+// TestToggleAOFConcurrentDisableIsRaceFree exercises concurrent CONFIG SET
+// appendonly no calls, the pattern two clients racing to disable
+// persistence hits; run with -race to catch a double close(stopSync) panic.
+func TestToggleAOFConcurrentDisableIsRaceFree(t *testing.T) {
+	dir := t.TempDir()
+
+	f := New(WithDatabases(1))
+	f.SetConfig("dir", dir)
+	f.SetConfig("appendfilename", "concurrent-toggle.aof")
+	f.SetConfig("appendonly", "yes")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.SetConfig("appendonly", "no")
+		}()
+	}
+	wg.Wait()
+
+	if f.aof != nil {
+		t.Fatal("f.aof still set after disabling AOF")
+	}
+}
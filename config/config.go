@@ -1,71 +1,106 @@
 package fluxdb
 
 import (
-	"bufio"
-	"fmt"
 	"io"
 	"log"
 	"net"
 	"strconv"
 	"strings"
 	"sync"
+
+	"github.com/saivenkatram-git/fluxdb/config/resp"
 )
 
 type FluxDB struct {
-	data   map[string]string
 	mu     sync.RWMutex
 	config map[string]string
+
+	// Logical databases: dbs holds one keyspace per SELECT-able index,
+	// numDBs is its fixed size, and dbsMu guards the slice itself (index
+	// assignment for SWAPDB) separately from each keyspace's own lock (see
+	// db.go).
+	dbsMu  sync.RWMutex
+	dbs    []*keyspace
+	numDBs int
+
+	// pub/sub state: channels maps a channel name to its direct subscribers,
+	// patterns maps a glob pattern to its subscribers (see pubsub.go).
+	pubsubMu sync.RWMutex
+	channels map[string]map[*client]bool
+	patterns map[string]map[*client]bool
+
+	// aof is non-nil while persistence is enabled (by setupAOF at startup or
+	// by toggleAOF on CONFIG SET appendonly); loading
+	// is set while replaying it so the replayed commands aren't re-appended.
+	// aofMu guards the aof field itself (swapped by toggleAOF) separately
+	// from the aof value's own mu (which guards its file, see aof.go).
+	aof     *aof
+	loading bool
+	aofMu   sync.RWMutex
+
+	// Command registry: handlers holds the commands registered via
+	// Register, middleware is the chain applied to each by Use, and
+	// PreHook/PostHook run around every registry dispatch (see registry.go).
+	handlersMu sync.RWMutex
+	handlers   map[string]Handler
+	middleware []func(Handler) Handler
+	PreHook    func(Conn, Command)
+	PostHook   func(Conn, Command)
+
+	// Accept, if set, is consulted for every new connection before it is
+	// served; returning false closes the connection immediately. Closed,
+	// if set, is called once the connection has finished serving commands.
+	Accept func(conn net.Conn) bool
+	Closed func(conn net.Conn, err error)
+}
+
+// Option configures a FluxDB before it starts serving commands. See New().
+type Option func(*FluxDB)
+
+// WithDatabases overrides the number of logical databases from the default
+// of 16 (see initDBs in db.go). Must be passed to New(); the count is fixed
+// for the server's lifetime.
+func WithDatabases(n int) Option {
+	return func(f *FluxDB) {
+		f.config["databases"] = strconv.Itoa(n)
+	}
+}
+
+// WithConfig seeds a config key before New() acts on it, for settings like
+// appendonly/appendfilename/appendfsync/dir that New() reads (via setupAOF)
+// before returning - setting them with SetConfig afterwards is too late to
+// affect startup behaviour such as AOF replay.
+func WithConfig(key, value string) Option {
+	return func(f *FluxDB) {
+		f.config[key] = value
+	}
 }
 
-func New() *FluxDB {
+func New(opts ...Option) *FluxDB {
 
 	fluxdb := &FluxDB{
-		data:   make(map[string]string),
-		config: make(map[string]string),
+		config:   make(map[string]string),
+		channels: make(map[string]map[*client]bool),
+		patterns: make(map[string]map[*client]bool),
+		handlers: make(map[string]Handler),
 	}
 
 	fluxdb.config["port"] = "6379"
 	fluxdb.config["bind"] = "0.0.0.0"
 	fluxdb.config["max_clients"] = "10000"
 	fluxdb.config["timeout"] = "0"
+	aofDefaults(fluxdb.config)
 
-	return fluxdb
-}
-
-// REDIS OPERATIONS ----------------------------------------------------------------------------------------------------
-
-// Set - set a key value pair
-func (f *FluxDB) Set(key string, value string) string {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-
-	// check if key exists
-	if _, exists := f.data[key]; exists {
-		return "false"
+	for _, opt := range opts {
+		opt(fluxdb)
 	}
 
-	// set key
-	f.data[key] = value
-	return "OK"
-}
+	fluxdb.initDBs()
+	fluxdb.registerBuiltins()
+	fluxdb.setupAOF()
+	fluxdb.startExpirySweeper()
 
-// Get - get a key value pair
-func (f *FluxDB) Get(key string) string {
-	f.mu.RLock()
-	defer f.mu.RUnlock()
-
-	if val, exists := f.data[key]; exists {
-		return val
-	}
-	return "nil"
-}
-
-// Delete - delete a key value pair
-func (f *FluxDB) Delete(key string) string {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	delete(f.data, key)
-	return "OK"
+	return fluxdb
 }
 
 // REDIS CONFIG OPERATIONS -----------------------------------------------------------------------------------------------
@@ -73,8 +108,13 @@ func (f *FluxDB) Delete(key string) string {
 // SetConfig - set a config value
 func (f *FluxDB) SetConfig(key string, value string) string {
 	f.mu.Lock()
-	defer f.mu.Unlock()
 	f.config[key] = value
+	f.mu.Unlock()
+
+	if key == "appendonly" {
+		f.toggleAOF(value)
+	}
+
 	return "OK"
 }
 
@@ -90,282 +130,252 @@ func (f *FluxDB) GetConfig(key string) string {
 }
 
 // RESP PROTOCOL --------------------------------------------------------------------------------------------------------
-
-/**
-* RESP Protocol - is used for client-server communication. RESP works on prefixes to indicate the specific data type. [Ends with \r\n]
-* The following prefixes are used:
-* + Simple Strings - eg: +OK\r\n
-* - Errors - eg: -ERR unknown command 'foobar'\r\n
-* : Integers - eg: :1000\r\n
-* $ Bulk Strings - eg: $6\r\nfoobar\r\n (here, 6 is the length of the string) (if null string is being passed then the length is -1)
-* * Arrays - eg: *3\r\n$3\r\nfoo\r\n$3\r\nbar\r\n$3\r\nbaz\r\n (here, 3 is the number of elements in the array)
-*
-* SIMPLE COMMAND IMPLEMENTATION: GET token -> *2\r\n$3\r\nGET\r\n$5\r\n{token}\r\n
-* RESP Protocol - https://redis.io/docs/reference/protocol-spec/
- */
-
-func writeString(w io.Writer, s string) {
-	fmt.Fprintf(w, "+%s\r\n", s)
-}
-
-func writeError(w io.Writer, s string) {
-	fmt.Fprintf(w, "-%s\r\n", s)
-}
-
-func writeInteger(w io.Writer, i int) {
-	fmt.Fprintf(w, ":%d\r\n", i)
-}
-
-func writeBulkString(w io.Writer, s string) {
-	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(s), s)
-}
-
-func writeArray(w io.Writer, arr []string) {
-	fmt.Fprintf(w, "*%d\r\n", len(arr))
-	for _, s := range arr {
-		writeBulkString(w, s)
+//
+// RESP Protocol - is used for client-server communication. RESP works on prefixes to indicate the specific data type. [Ends with \r\n]
+// The following prefixes are used:
+//   +  Simple Strings - eg: +OK\r\n
+//   -  Errors - eg: -ERR unknown command 'foobar'\r\n
+//   :  Integers - eg: :1000\r\n
+//   $  Bulk Strings - eg: $6\r\nfoobar\r\n (here, 6 is the length of the string) (if null string is being passed then the length is -1)
+//   *  Arrays - eg: *3\r\n$3\r\nfoo\r\n$3\r\nbar\r\n$3\r\nbaz\r\n (here, 3 is the number of elements in the array)
+//
+// SIMPLE COMMAND IMPLEMENTATION: GET token -> *2\r\n$3\r\nGET\r\n$5\r\n{token}\r\n
+// RESP Protocol - https://redis.io/docs/reference/protocol-spec/
+//
+// Parsing and framing are handled by the resp subpackage, whose Reader and
+// Writer avoid the per-command allocations of a bufio.Reader.ReadString +
+// strings.Fields parser and fmt.Fprintf-based replies, and support
+// draining/flushing a full pipeline in a single syscall.
+
+// toStrings converts parsed command args to strings for the handlers below,
+// which predate the []byte-based resp.Command and still operate on strings.
+func toStrings(args [][]byte) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		out[i] = string(a)
 	}
+	return out
 }
 
-// PARSER ---------------------------------------------------------------------------------------------------------------
-
-func parseRESP(reader *bufio.Reader) ([]string, error) {
-	// Read the first byte to determine the type
-	b, err := reader.ReadByte()
-	if err != nil {
-		return nil, err
-	}
-
-	switch b {
-	case '*': // Array
-		return parseArray(reader)
-	case '$': // Bulk String
-		s, err := parseBulkString(reader)
-		if err != nil {
-			return nil, err
-		}
-		return []string{s}, nil
-
-	default:
-		// For simplicity, try to handle simple strings or inline commands
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			return nil, err
-		}
-		line = strings.TrimSpace(line)
-		return strings.Fields(string(b) + line), nil
+const helpText = "Available commands:\r\n" +
+	"PING - Test connection\r\n" +
+	"SET key value - Set a key value pair\r\n" +
+	"GET key - Get a key value pair\r\n" +
+	"DEL key - Delete a key value pair\r\n" +
+	"CONFIG GET/SET - View or modify configuration\r\n" +
+	"SELECT db - Select a logical database\r\n" +
+	"FLUSHDB/FLUSHALL - Clear the current/all logical databases\r\n" +
+	"SWAPDB i j - Swap two logical databases\r\n" +
+	"DBSIZE - Count keys in the current database\r\n" +
+	"MOVE key db - Move a key to another logical database\r\n" +
+	"KEYS pattern - List keys matching a glob pattern\r\n" +
+	"EXPIRE key seconds / TTL key / PERSIST key - Manage key expiration\r\n" +
+	"HSET/HGET/HGETALL/HDEL - Hash field operations\r\n" +
+	"LPUSH/RPUSH/LPOP/RPOP/LRANGE/LLEN - List operations\r\n" +
+	"SADD/SREM/SMEMBERS/SISMEMBER/SINTER/SUNION - Set operations\r\n" +
+	"ZADD/ZRANGE/ZRANGEBYSCORE/ZSCORE/ZRANK - Sorted set operations\r\n" +
+	"SUBSCRIBE/PSUBSCRIBE channel - Listen for published messages\r\n" +
+	"UNSUBSCRIBE/PUNSUBSCRIBE channel - Stop listening for published messages\r\n" +
+	"PUBLISH channel message - Publish a message to a channel\r\n" +
+	"PUBSUB CHANNELS/NUMSUB/NUMPAT - Inspect pub/sub state\r\n" +
+	"AOF REWRITE / BGREWRITEAOF - Compact the append-only file\r\n" +
+	"HELP - Show this help"
+
+// processCommand handles Redis commands. Commands registered via Register
+// (see registry.go) are dispatched there; everything else - pub/sub and AOF
+// control, so far - is handled directly below pending migration to the
+// registry.
+func (r *FluxDB) processCommand(cmd []string, c *client, w *resp.Writer) {
+	if len(cmd) == 0 {
+		return
 	}
-}
 
-// parseArray parses a RESP array
-func parseArray(reader *bufio.Reader) ([]string, error) {
-	// Read array length
-	line, err := reader.ReadString('\n')
-	if err != nil {
-		return nil, err
-	}
+	command := strings.ToUpper(cmd[0])
+	args := cmd[1:]
 
-	line = strings.TrimSpace(line)
-	count, err := strconv.Atoi(line)
-	if err != nil {
-		return nil, fmt.Errorf("invalid array length: %s", line)
+	if c.inSubscribeMode() && !allowedWhileSubscribed(command) {
+		w.WriteError("only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PUBSUB / PING / QUIT allowed in this context")
+		return
 	}
 
-	if count < 0 {
-		return nil, nil // Null array
+	if r.dispatch(c, Command{Name: command, Args: args}) {
+		return
 	}
 
-	result := make([]string, 0, count)
-	for i := 0; i < count; i++ {
-		b, err := reader.ReadByte()
-		if err != nil {
-			return nil, err
+	switch command {
+	case "SUBSCRIBE":
+		if len(args) < 1 {
+			w.WriteError("wrong number of arguments for 'subscribe' command")
+			return
 		}
-
-		if b != '$' {
-			return nil, fmt.Errorf("expected bulk string in array, got: %c", b)
+		for _, channel := range args {
+			count := r.subscribe(c, channel)
+			writeSubscribeReply(w, "subscribe", channel, count)
 		}
 
-		reader.UnreadByte()
-		s, err := parseBulkString(reader)
-		if err != nil {
-			return nil, err
+	case "UNSUBSCRIBE":
+		channels := args
+		if len(channels) == 0 {
+			c.subMu.Lock()
+			for ch := range c.channels {
+				channels = append(channels, ch)
+			}
+			c.subMu.Unlock()
+		}
+		if len(channels) == 0 {
+			writeSubscribeReply(w, "unsubscribe", "", 0)
+			return
+		}
+		for _, channel := range channels {
+			count := r.unsubscribe(c, channel)
+			writeSubscribeReply(w, "unsubscribe", channel, count)
 		}
 
-		result = append(result, s)
-	}
-
-	return result, nil
-}
-
-// parseBulkString parses a RESP bulk string
-func parseBulkString(reader *bufio.Reader) (string, error) {
-	// Read the $ character
-	_, err := reader.ReadByte()
-	if err != nil {
-		return "", err
-	}
-
-	// Read length
-	line, err := reader.ReadString('\n')
-	if err != nil {
-		return "", err
-	}
-
-	line = strings.TrimSpace(line)
-	length, err := strconv.Atoi(line)
-	if err != nil {
-		return "", fmt.Errorf("invalid bulk string length: %s", line)
-	}
-
-	if length < 0 {
-		return "", nil // Null bulk string
-	}
-
-	// Read the string content
-	buf := make([]byte, length+2) // +2 for \r\n
-	_, err = io.ReadFull(reader, buf)
-	if err != nil {
-		return "", err
-	}
-
-	return string(buf[:length]), nil
-}
-
-// processCommand handles Redis commands
-func (r *FluxDB) processCommand(cmd []string, conn net.Conn) {
-	if len(cmd) == 0 {
-		return
-	}
-
-	command := strings.ToUpper(cmd[0])
-	args := cmd[1:]
-
-	switch command {
-	case "PING":
-		if len(args) == 0 {
-			writeString(conn, "PONG")
-		} else {
-			writeBulkString(conn, args[0])
+	case "PSUBSCRIBE":
+		if len(args) < 1 {
+			w.WriteError("wrong number of arguments for 'psubscribe' command")
+			return
+		}
+		for _, pattern := range args {
+			count := r.psubscribe(c, pattern)
+			writeSubscribeReply(w, "psubscribe", pattern, count)
 		}
 
-	case "SET":
-		if len(args) < 2 {
-			writeError(conn, "wrong number of arguments for 'set' command")
+	case "PUNSUBSCRIBE":
+		patterns := args
+		if len(patterns) == 0 {
+			c.subMu.Lock()
+			for p := range c.patterns {
+				patterns = append(patterns, p)
+			}
+			c.subMu.Unlock()
+		}
+		if len(patterns) == 0 {
+			writeSubscribeReply(w, "punsubscribe", "", 0)
 			return
 		}
-		result := r.Set(args[0], args[1])
-		writeString(conn, result)
+		for _, pattern := range patterns {
+			count := r.punsubscribe(c, pattern)
+			writeSubscribeReply(w, "punsubscribe", pattern, count)
+		}
 
-	case "GET":
-		if len(args) < 1 {
-			writeError(conn, "wrong number of arguments for 'get' command")
+	case "PUBLISH":
+		if len(args) < 2 {
+			w.WriteError("wrong number of arguments for 'publish' command")
 			return
 		}
-		result := r.Get(args[0])
-		writeBulkString(conn, result)
+		w.WriteInt(r.publish(args[0], args[1]))
 
-	case "DEL":
+	case "PUBSUB":
 		if len(args) < 1 {
-			writeError(conn, "wrong number of arguments for 'del' command")
+			w.WriteError("wrong number of arguments for 'pubsub' command")
 			return
 		}
-		count := 0
-		for _, key := range args {
-			deleted := r.Delete(key)
-			if deleted == "true" {
-				count++
+		switch strings.ToUpper(args[0]) {
+		case "CHANNELS":
+			pattern := ""
+			if len(args) > 1 {
+				pattern = args[1]
 			}
+			writeStringArray(w, r.pubsubChannels(pattern))
+		case "NUMSUB":
+			writeStringArray(w, r.pubsubNumSub(args[1:]))
+		case "NUMPAT":
+			w.WriteInt(r.pubsubNumPat())
+		default:
+			w.WriteError("unknown PUBSUB subcommand '" + args[0] + "'")
 		}
-		writeInteger(conn, count)
-
-	case "HELP":
-		helpText := "Available commands:\r\n" +
-			"PING - Test connection\r\n" +
-			"SET key value - Set a key value pair\r\n" +
-			"GET key - Get a key value pair\r\n" +
-			"DEL key - Delete a key value pair\r\n" +
-			"CONFIG GET/SET - View or modify configuration\r\n" +
-			"SELECT db - Select a logical database\r\n" +
-			"HELP - Show this help"
 
-		writeBulkString(conn, helpText)
+	case "QUIT":
+		w.WriteString("OK")
 
-	case "SELECT":
-		if len(args) < 1 {
-			writeError(conn, "wrong number of arguments for 'select' command")
+	case "BGREWRITEAOF":
+		if err := r.rewrite(); err != nil {
+			w.WriteError(err.Error())
 			return
 		}
+		w.WriteString("Background append only file rewriting started")
 
-		writeString(conn, "OK")
-
-	case "CONFIG":
+	case "AOF":
 		if len(args) < 1 {
-			writeError(conn, "wrong number of arguments for 'config' command")
+			w.WriteError("wrong number of arguments for 'aof' command")
 			return
 		}
-
-		subcommand := strings.ToUpper(args[0])
-		switch subcommand {
-		case "GET":
-			if len(args) < 2 {
-				writeError(conn, "wrong number of arguments for 'config get' command")
-				return
-			}
-
-			pattern := args[1]
-			result := []string{}
-
-			// Simple pattern matching (supporting only exact matches and '*')
-			if pattern == "*" {
-				// Return all config values
-				for k, v := range r.config {
-					result = append(result, k, v)
-				}
-			} else {
-				// Return specific config value
-				if val := r.GetConfig(pattern); val != "" {
-					result = append(result, pattern, val)
-				}
-			}
-
-			writeArray(conn, result)
-
-		case "SET":
-			if len(args) < 3 {
-				writeError(conn, "wrong number of arguments for 'config set' command")
+		switch strings.ToUpper(args[0]) {
+		case "REWRITE":
+			if err := r.rewrite(); err != nil {
+				w.WriteError(err.Error())
 				return
 			}
-
-			r.SetConfig(args[1], args[2])
-			writeString(conn, "OK")
-
+			w.WriteString("OK")
 		default:
-			writeError(conn, "unsupported config operation")
+			w.WriteError("unsupported AOF operation")
 		}
 
 	default:
-		writeError(conn, "unknown command '"+command+"'")
+		w.WriteError("unknown command '" + command + "'")
+	}
+}
+
+// writeStringArray buffers a RESP array of bulk strings.
+func writeStringArray(w *resp.Writer, arr []string) {
+	w.WriteArray(len(arr))
+	for _, s := range arr {
+		w.WriteBulk([]byte(s))
 	}
 }
 
 func (r *FluxDB) HandleConnection(conn net.Conn) {
+	if r.Accept != nil && !r.Accept(conn) {
+		conn.Close()
+		return
+	}
 	defer conn.Close()
-	reader := bufio.NewReader(conn)
+
+	c := newClient(conn)
+	go c.runWriter()
+
+	var connErr error
+	defer func() {
+		r.unsubscribeAll(c)
+		close(c.outCh)
+		if r.Closed != nil {
+			r.Closed(conn, connErr)
+		}
+	}()
+
+	reader := resp.NewReader(conn)
+	writer := resp.NewWriter(c)
+	c.writer = writer
+	c.remoteAddr = conn.RemoteAddr().String()
 
 	for {
-		cmd, err := parseRESP(reader)
+		command, err := reader.ReadCommand()
 		if err != nil {
 			if err != io.EOF {
+				connErr = err
 				log.Printf("Error parsing command: %v", err)
 			}
+			writer.Flush()
 			return
 		}
 
-		if len(cmd) == 0 {
+		if len(command.Args) == 0 {
 			continue
 		}
 
-		r.processCommand(cmd, conn)
+		cmd := toStrings(command.Args)
+		r.processCommand(cmd, c, writer)
+
+		// Only flush once the pipeline is drained, so N pipelined commands
+		// produce a single Write instead of N.
+		if reader.Buffered() == 0 {
+			writer.Flush()
+		}
+
+		if strings.ToUpper(cmd[0]) == "QUIT" {
+			writer.Flush()
+			return
+		}
 	}
 }
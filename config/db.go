@@ -0,0 +1,274 @@
+package fluxdb
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LOGICAL DATABASES -------------------------------------------------------------------------------------------------
+//
+// FluxDB partitions its keyspace into a fixed number of logical databases,
+// mirroring Redis's SELECT model: each connection has its own selected
+// index (see client.db), and Set/Get/Delete operate against whichever
+// keyspace that connection currently has selected rather than a single
+// shared map. The number of databases is fixed at startup from the
+// "databases" config key (default 16).
+
+// valueType tags what kind of value an entry holds, so type-specific
+// commands (HGET, LPUSH, ...) can refuse to operate on the wrong shape
+// instead of silently misreading it.
+type valueType int
+
+const (
+	typeString valueType = iota
+	typeList
+	typeHash
+	typeSet
+	typeZSet
+)
+
+// errWrongType is returned by any command applied to a key holding a value
+// of a different type, matching Redis's WRONGTYPE error.
+var errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// entry is a single stored value: exactly one of the fields matching typ is
+// populated. expireAt is the deadline set by EXPIRE; the zero Time means the
+// key never expires (see expire.go).
+type entry struct {
+	typ valueType
+
+	str  string
+	list []string
+	hash map[string]string
+	set  map[string]struct{}
+	zset *zset
+
+	expireAt time.Time
+}
+
+// expired reports whether e's TTL has passed.
+func (e *entry) expired() bool {
+	return !e.expireAt.IsZero() && !time.Now().Before(e.expireAt)
+}
+
+// keyspace is one logical database: an isolated key/value map with its own
+// lock, so operations on database N never contend with database M.
+type keyspace struct {
+	mu   sync.RWMutex
+	data map[string]*entry
+}
+
+func newKeyspace() *keyspace {
+	return &keyspace{data: make(map[string]*entry)}
+}
+
+const defaultDatabases = 16
+
+// initDBs allocates the configured number of logical databases; called
+// once from New(). The "databases" config key is honored if a caller
+// already set one (see WithDatabases), otherwise it's seeded with the
+// default of 16.
+func (f *FluxDB) initDBs() {
+	n := defaultDatabases
+	if configured, ok := f.config["databases"]; ok {
+		if parsed, err := strconv.Atoi(configured); err == nil && parsed > 0 {
+			n = parsed
+		}
+	}
+	f.config["databases"] = strconv.Itoa(n)
+
+	f.numDBs = n
+	f.dbs = make([]*keyspace, f.numDBs)
+	for i := range f.dbs {
+		f.dbs[i] = newKeyspace()
+	}
+}
+
+// keyspaceAt returns the keyspace for db, falling back to database 0 for an
+// out-of-range index so a stale or bad SELECT can never panic a lookup.
+func (f *FluxDB) keyspaceAt(db int) *keyspace {
+	f.dbsMu.RLock()
+	defer f.dbsMu.RUnlock()
+
+	if db < 0 || db >= len(f.dbs) {
+		return f.dbs[0]
+	}
+	return f.dbs[db]
+}
+
+// lookup returns the live entry for key, evicting it in place first if its
+// TTL has passed. Callers must hold ks.mu for writing: a lookup can mutate
+// the map, not just read it.
+func (ks *keyspace) lookup(key string) (*entry, bool) {
+	e, ok := ks.data[key]
+	if !ok {
+		return nil, false
+	}
+	if e.expired() {
+		delete(ks.data, key)
+		return nil, false
+	}
+	return e, true
+}
+
+// Set - set a key value pair in the given logical database. Matches Redis:
+// SET always overwrites whatever was previously stored at key, including a
+// value of a different type, and clears any TTL the key had.
+func (f *FluxDB) Set(db int, key string, value string) string {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.data[key] = &entry{typ: typeString, str: value}
+	f.appendIfMutating(db, []string{"SET", key, value})
+	return "OK"
+}
+
+// Get - get a key value pair from the given logical database. Returns
+// errWrongType if key holds a non-string value.
+func (f *FluxDB) Get(db int, key string) (string, bool, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, exists := ks.lookup(key)
+	if !exists {
+		return "", false, nil
+	}
+	if e.typ != typeString {
+		return "", false, errWrongType
+	}
+	return e.str, true, nil
+}
+
+// Delete - delete a key value pair from the given logical database,
+// regardless of its type. Reports "true" if a key was actually removed.
+func (f *FluxDB) Delete(db int, key string) string {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if _, exists := ks.lookup(key); !exists {
+		return "false"
+	}
+	delete(ks.data, key)
+	f.appendIfMutating(db, []string{"DEL", key})
+	return "true"
+}
+
+// FlushDB clears every key in the given logical database.
+func (f *FluxDB) FlushDB(db int) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.data = make(map[string]*entry)
+	f.appendIfMutating(db, []string{"FLUSHDB"})
+}
+
+// FlushAll clears every key in every logical database. Unlike the other
+// mutators here, it does not log to the AOF itself; the FLUSHALL command
+// handler (see registry.go) logs it after FlushAll returns. Since FlushAll
+// takes each keyspace's lock in turn rather than holding a single lock for
+// the whole operation, a command racing a single database within the flush
+// can still log either side of it. FLUSHALL clearing everything makes that
+// ordering harmless in practice.
+func (f *FluxDB) FlushAll() {
+	f.dbsMu.RLock()
+	defer f.dbsMu.RUnlock()
+	for _, ks := range f.dbs {
+		ks.mu.Lock()
+		ks.data = make(map[string]*entry)
+		ks.mu.Unlock()
+	}
+}
+
+// SwapDB exchanges the contents of logical databases i and j in place, so
+// every connection currently selecting either index sees the other's data.
+func (f *FluxDB) SwapDB(i, j int) error {
+	f.dbsMu.Lock()
+	defer f.dbsMu.Unlock()
+
+	if i < 0 || i >= len(f.dbs) || j < 0 || j >= len(f.dbs) {
+		return fmt.Errorf("DB index is out of range")
+	}
+
+	f.dbs[i], f.dbs[j] = f.dbs[j], f.dbs[i]
+	f.appendIfMutating(i, []string{"SWAPDB", strconv.Itoa(i), strconv.Itoa(j)})
+	return nil
+}
+
+// DBSize returns the number of live (non-expired) keys in the given logical
+// database.
+func (f *FluxDB) DBSize(db int) int {
+	ks := f.keyspaceAt(db)
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	count := 0
+	for _, e := range ks.data {
+		if !e.expired() {
+			count++
+		}
+	}
+	return count
+}
+
+// Move relocates key from database src to database dst, reporting whether
+// the move happened (it fails if the key doesn't exist in src or already
+// exists in dst, matching Redis's MOVE).
+func (f *FluxDB) Move(src int, key string, dst int) bool {
+	if src == dst {
+		return false
+	}
+
+	srcKS := f.keyspaceAt(src)
+	dstKS := f.keyspaceAt(dst)
+
+	// Lock in a fixed order (by db index) so two MOVEs in opposite
+	// directions can't deadlock on each other's keyspace.
+	first, second := srcKS, dstKS
+	if dst < src {
+		first, second = dstKS, srcKS
+	}
+	first.mu.Lock()
+	defer first.mu.Unlock()
+	if second != first {
+		second.mu.Lock()
+		defer second.mu.Unlock()
+	}
+
+	val, exists := srcKS.lookup(key)
+	if !exists {
+		return false
+	}
+	if _, exists := dstKS.lookup(key); exists {
+		return false
+	}
+
+	delete(srcKS.data, key)
+	dstKS.data[key] = val
+	f.appendIfMutating(src, []string{"MOVE", key, strconv.Itoa(dst)})
+	return true
+}
+
+// Keys returns every live key in db whose name matches pattern (Redis glob
+// syntax; "*" or "" matches everything).
+func (f *FluxDB) Keys(db int, pattern string) []string {
+	ks := f.keyspaceAt(db)
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	result := []string{}
+	for k, e := range ks.data {
+		if e.expired() {
+			continue
+		}
+		if pattern == "" || pattern == "*" || globMatch(pattern, k) {
+			result = append(result, k)
+		}
+	}
+	return result
+}
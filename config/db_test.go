@@ -0,0 +1,73 @@
+package fluxdb
+
+import "testing"
+
+// TestDBIsolation verifies that keys set in one logical database are
+// invisible from another, and that FlushDB only clears the selected one.
+func TestDBIsolation(t *testing.T) {
+	f := New(WithDatabases(4))
+
+	f.Set(0, "k", "db0")
+	f.Set(1, "k", "db1")
+
+	v0, _, _ := f.Get(0, "k")
+	v1, _, _ := f.Get(1, "k")
+	if v0 != "db0" || v1 != "db1" {
+		t.Fatalf("Get(0)=%q Get(1)=%q, want db0/db1 isolated", v0, v1)
+	}
+
+	f.FlushDB(0)
+	_, ok0, _ := f.Get(0, "k")
+	v1, _, _ = f.Get(1, "k")
+	if ok0 {
+		t.Fatal("FlushDB(0) left k present, want gone")
+	}
+	if v1 != "db1" {
+		t.Fatalf("FlushDB(0) affected db 1: k = %q, want db1", v1)
+	}
+}
+
+func TestSwapDB(t *testing.T) {
+	f := New(WithDatabases(2))
+	f.Set(0, "k", "zero")
+	f.Set(1, "k", "one")
+
+	if err := f.SwapDB(0, 1); err != nil {
+		t.Fatalf("SwapDB: %v", err)
+	}
+
+	v0, _, _ := f.Get(0, "k")
+	v1, _, _ := f.Get(1, "k")
+	if v0 != "one" || v1 != "zero" {
+		t.Fatalf("after SwapDB(0,1): Get(0)=%q Get(1)=%q, want one/zero", v0, v1)
+	}
+}
+
+func TestMove(t *testing.T) {
+	f := New(WithDatabases(2))
+	f.Set(0, "k", "v")
+
+	if !f.Move(0, "k", 1) {
+		t.Fatal("Move returned false for an existing key")
+	}
+	if _, ok, _ := f.Get(0, "k"); ok {
+		t.Fatal("key still present in source db")
+	}
+	if v, _, _ := f.Get(1, "k"); v != "v" {
+		t.Fatalf("key missing from destination db: %q", v)
+	}
+
+	if f.Move(0, "missing", 1) {
+		t.Fatal("Move returned true for a nonexistent key")
+	}
+}
+
+func TestWithDatabasesOverridesDefault(t *testing.T) {
+	f := New(WithDatabases(32))
+	if f.numDBs != 32 {
+		t.Fatalf("numDBs = %d, want 32", f.numDBs)
+	}
+	if got := f.GetConfig("databases"); got != "32" {
+		t.Fatalf(`GetConfig("databases") = %q, want "32"`, got)
+	}
+}
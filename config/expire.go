@@ -0,0 +1,95 @@
+package fluxdb
+
+import (
+	"strconv"
+	"time"
+)
+
+// KEY EXPIRATION -----------------------------------------------------------------------------------------------------
+//
+// Keys can carry a TTL (entry.expireAt) set by EXPIRE and cleared by PERSIST
+// or by any command that replaces the key's value (SET, the type-create
+// path of HSET/LPUSH/SADD/ZADD, ...). Expiry is enforced two ways, matching
+// Redis: lazily, via keyspace.lookup on every access, and actively, via a
+// background sweeper that periodically evicts expired keys that nobody has
+// touched since they expired.
+
+// sweepInterval is how often the background sweeper scans each keyspace for
+// expired keys.
+const sweepInterval = 100 * time.Millisecond
+
+// startExpirySweeper launches the background goroutine that evicts expired
+// keys across every logical database; called once from New().
+func (f *FluxDB) startExpirySweeper() {
+	go func() {
+		ticker := time.NewTicker(sweepInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			f.sweepExpired()
+		}
+	}()
+}
+
+// sweepExpired removes every expired key from every logical database.
+func (f *FluxDB) sweepExpired() {
+	f.dbsMu.RLock()
+	defer f.dbsMu.RUnlock()
+
+	for _, ks := range f.dbs {
+		ks.mu.Lock()
+		for key, e := range ks.data {
+			if e.expired() {
+				delete(ks.data, key)
+			}
+		}
+		ks.mu.Unlock()
+	}
+}
+
+// Expire sets key to expire after ttl, reporting whether key existed.
+func (f *FluxDB) Expire(db int, key string, ttl time.Duration) bool {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, exists := ks.lookup(key)
+	if !exists {
+		return false
+	}
+	e.expireAt = time.Now().Add(ttl)
+	f.appendIfMutating(db, []string{"EXPIRE", key, strconv.Itoa(int(ttl.Seconds()))})
+	return true
+}
+
+// TTL returns the number of whole seconds until key expires, -1 if key
+// exists but has no TTL, or -2 if key does not exist - matching Redis's
+// TTL command.
+func (f *FluxDB) TTL(db int, key string) int {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, exists := ks.lookup(key)
+	if !exists {
+		return -2
+	}
+	if e.expireAt.IsZero() {
+		return -1
+	}
+	return int(time.Until(e.expireAt).Seconds())
+}
+
+// Persist removes key's TTL, reporting whether a TTL was actually cleared.
+func (f *FluxDB) Persist(db int, key string) bool {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, exists := ks.lookup(key)
+	if !exists || e.expireAt.IsZero() {
+		return false
+	}
+	e.expireAt = time.Time{}
+	f.appendIfMutating(db, []string{"PERSIST", key})
+	return true
+}
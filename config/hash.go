@@ -0,0 +1,126 @@
+package fluxdb
+
+// HASHES -------------------------------------------------------------------------------------------------------------
+//
+// A hash entry stores a map[string]string under a single key, the same
+// shape Redis hashes have. Commands follow the Set/Get/Delete pattern used
+// throughout db.go: each takes the logical db index first and returns
+// errWrongType if key already holds a non-hash value.
+
+// hashAt returns the hash entry at key, creating an empty one if key is
+// absent. Callers must hold ks.mu for writing.
+func (ks *keyspace) hashAt(key string) (*entry, error) {
+	e, exists := ks.lookup(key)
+	if !exists {
+		e = &entry{typ: typeHash, hash: make(map[string]string)}
+		ks.data[key] = e
+		return e, nil
+	}
+	if e.typ != typeHash {
+		return nil, errWrongType
+	}
+	return e, nil
+}
+
+// interleave zips fields and values back into a flat [field, value, field,
+// value, ...] slice, the shape HSET's AOF log entry needs to replay cleanly.
+func interleave(fields, values []string) []string {
+	out := make([]string, 0, 2*len(fields))
+	for i, field := range fields {
+		out = append(out, field, values[i])
+	}
+	return out
+}
+
+// HSet sets fields[i] to values[i] in the hash at key, creating the hash if
+// it doesn't exist, and returns the number of fields that were newly added
+// (fields that already existed are updated but not counted, matching
+// Redis's HSET).
+func (f *FluxDB) HSet(db int, key string, fields, values []string) (int, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, err := ks.hashAt(key)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for i, field := range fields {
+		if _, exists := e.hash[field]; !exists {
+			added++
+		}
+		e.hash[field] = values[i]
+	}
+	f.appendIfMutating(db, append([]string{"HSET", key}, interleave(fields, values)...))
+	return added, nil
+}
+
+// HGet returns the value of field in the hash at key.
+func (f *FluxDB) HGet(db int, key, field string) (string, bool, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, exists := ks.lookup(key)
+	if !exists {
+		return "", false, nil
+	}
+	if e.typ != typeHash {
+		return "", false, errWrongType
+	}
+	val, ok := e.hash[field]
+	return val, ok, nil
+}
+
+// HGetAll returns every field/value pair in the hash at key.
+func (f *FluxDB) HGetAll(db int, key string) (map[string]string, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, exists := ks.lookup(key)
+	if !exists {
+		return map[string]string{}, nil
+	}
+	if e.typ != typeHash {
+		return nil, errWrongType
+	}
+
+	out := make(map[string]string, len(e.hash))
+	for k, v := range e.hash {
+		out[k] = v
+	}
+	return out, nil
+}
+
+// HDel removes fields from the hash at key and returns how many were
+// actually present. The key itself is removed once its last field is gone,
+// matching Redis.
+func (f *FluxDB) HDel(db int, key string, fields []string) (int, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, exists := ks.lookup(key)
+	if !exists {
+		return 0, nil
+	}
+	if e.typ != typeHash {
+		return 0, errWrongType
+	}
+
+	removed := 0
+	for _, field := range fields {
+		if _, ok := e.hash[field]; ok {
+			delete(e.hash, field)
+			removed++
+		}
+	}
+	if len(e.hash) == 0 {
+		delete(ks.data, key)
+	}
+	f.appendIfMutating(db, append([]string{"HDEL", key}, fields...))
+	return removed, nil
+}
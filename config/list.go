@@ -0,0 +1,171 @@
+package fluxdb
+
+// LISTS --------------------------------------------------------------------------------------------------------------
+//
+// A list entry stores a []string, head at index 0. LPUSH/RPUSH grow it at
+// either end and LRANGE/LLEN read it back using Redis's negative-index
+// convention (-1 is the last element, -2 the second-to-last, ...).
+
+// listAt returns the list entry at key, creating an empty one if key is
+// absent. Callers must hold ks.mu for writing.
+func (ks *keyspace) listAt(key string) (*entry, error) {
+	e, exists := ks.lookup(key)
+	if !exists {
+		e = &entry{typ: typeList}
+		ks.data[key] = e
+		return e, nil
+	}
+	if e.typ != typeList {
+		return nil, errWrongType
+	}
+	return e, nil
+}
+
+// LPush inserts values at the head of the list at key, one at a time (so
+// the last element of values ends up first), creating the list if it
+// doesn't exist, and returns the list's new length.
+func (f *FluxDB) LPush(db int, key string, values []string) (int, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, err := ks.listAt(key)
+	if err != nil {
+		return 0, err
+	}
+	for _, v := range values {
+		e.list = append([]string{v}, e.list...)
+	}
+	f.appendIfMutating(db, append([]string{"LPUSH", key}, values...))
+	return len(e.list), nil
+}
+
+// RPush appends values to the tail of the list at key, creating the list if
+// it doesn't exist, and returns the list's new length.
+func (f *FluxDB) RPush(db int, key string, values []string) (int, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, err := ks.listAt(key)
+	if err != nil {
+		return 0, err
+	}
+	e.list = append(e.list, values...)
+	f.appendIfMutating(db, append([]string{"RPUSH", key}, values...))
+	return len(e.list), nil
+}
+
+// LPop removes and returns the first element of the list at key. The key is
+// removed once its last element is popped.
+func (f *FluxDB) LPop(db int, key string) (string, bool, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, exists := ks.lookup(key)
+	if !exists {
+		return "", false, nil
+	}
+	if e.typ != typeList {
+		return "", false, errWrongType
+	}
+	if len(e.list) == 0 {
+		return "", false, nil
+	}
+
+	val := e.list[0]
+	e.list = e.list[1:]
+	if len(e.list) == 0 {
+		delete(ks.data, key)
+	}
+	f.appendIfMutating(db, []string{"LPOP", key})
+	return val, true, nil
+}
+
+// RPop removes and returns the last element of the list at key. The key is
+// removed once its last element is popped.
+func (f *FluxDB) RPop(db int, key string) (string, bool, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, exists := ks.lookup(key)
+	if !exists {
+		return "", false, nil
+	}
+	if e.typ != typeList {
+		return "", false, errWrongType
+	}
+	if len(e.list) == 0 {
+		return "", false, nil
+	}
+
+	last := len(e.list) - 1
+	val := e.list[last]
+	e.list = e.list[:last]
+	if len(e.list) == 0 {
+		delete(ks.data, key)
+	}
+	f.appendIfMutating(db, []string{"RPOP", key})
+	return val, true, nil
+}
+
+// LLen returns the length of the list at key.
+func (f *FluxDB) LLen(db int, key string) (int, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, exists := ks.lookup(key)
+	if !exists {
+		return 0, nil
+	}
+	if e.typ != typeList {
+		return 0, errWrongType
+	}
+	return len(e.list), nil
+}
+
+// LRange returns the elements of the list at key between start and stop,
+// inclusive, interpreting negative indexes as counting from the tail (-1 is
+// the last element), same as Redis's LRANGE.
+func (f *FluxDB) LRange(db int, key string, start, stop int) ([]string, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, exists := ks.lookup(key)
+	if !exists {
+		return []string{}, nil
+	}
+	if e.typ != typeList {
+		return nil, errWrongType
+	}
+
+	n := len(e.list)
+	start = resolveListIndex(start, n)
+	stop = resolveListIndex(stop, n)
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop || n == 0 {
+		return []string{}, nil
+	}
+
+	out := make([]string, stop-start+1)
+	copy(out, e.list[start:stop+1])
+	return out, nil
+}
+
+// resolveListIndex converts a possibly-negative Redis list index into an
+// absolute one for a list of length n.
+func resolveListIndex(i, n int) int {
+	if i < 0 {
+		return n + i
+	}
+	return i
+}
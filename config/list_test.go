@@ -0,0 +1,43 @@
+package fluxdb
+
+import "testing"
+
+// TestLPopRPopWrongType verifies LPop/RPop report errWrongType for a key
+// holding a non-list value, the same check order every other typed command
+// (LLen, HGet, SIsMember, ...) applies before checking the value's length.
+func TestLPopRPopWrongType(t *testing.T) {
+	f := New()
+	f.Set(0, "k", "v")
+
+	if _, _, err := f.LPop(0, "k"); err != errWrongType {
+		t.Fatalf("LPop on a string key: err = %v, want errWrongType", err)
+	}
+	if _, _, err := f.RPop(0, "k"); err != errWrongType {
+		t.Fatalf("RPop on a string key: err = %v, want errWrongType", err)
+	}
+}
+
+func TestRPushThenPopBothEnds(t *testing.T) {
+	f := New()
+
+	if _, err := f.RPush(0, "list", []string{"a", "b", "c"}); err != nil {
+		t.Fatalf("RPush: %v", err)
+	}
+
+	val, ok, err := f.RPop(0, "list")
+	if err != nil || !ok || val != "c" {
+		t.Fatalf("RPop = %q, %v, %v; want c, true, nil", val, ok, err)
+	}
+
+	val, ok, err = f.LPop(0, "list")
+	if err != nil || !ok || val != "a" {
+		t.Fatalf("LPop = %q, %v, %v; want a, true, nil", val, ok, err)
+	}
+}
+
+func TestLPopEmptyListNotFound(t *testing.T) {
+	f := New()
+	if _, ok, err := f.LPop(0, "missing"); ok || err != nil {
+		t.Fatalf("LPop on a missing key: ok=%v err=%v, want false, nil", ok, err)
+	}
+}
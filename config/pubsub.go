@@ -0,0 +1,389 @@
+package fluxdb
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/saivenkatram-git/fluxdb/config/resp"
+)
+
+// PUB/SUB ----------------------------------------------------------------------------------------------------------
+//
+// FluxDB implements a Redis-compatible publish/subscribe subsystem. Clients
+// subscribe to exact channel names (SUBSCRIBE) or glob patterns (PSUBSCRIBE,
+// supporting '*', '?' and '[abc]' classes) and receive messages published to
+// matching channels (PUBLISH) as framed RESP pushes, independent of whatever
+// request/response exchange is happening on the same connection.
+
+// client tracks the per-connection state needed to serialize writes to a
+// socket and to know what a connection is currently subscribed to. All
+// command replies and pub/sub pushes for a connection flow through this
+// type so that a push delivered from another goroutine can never interleave
+// with a reply being written mid-frame.
+type client struct {
+	conn io.Writer
+
+	writeMu sync.Mutex
+
+	// outCh buffers pub/sub pushes so that PUBLISH never blocks on a slow
+	// subscriber; a dedicated goroutine drains it and writes to conn.
+	outCh chan []byte
+
+	subMu    sync.Mutex
+	channels map[string]bool
+	patterns map[string]bool
+
+	// writer and remoteAddr back the Conn interface (see registry.go) that
+	// registered command handlers see; writer is set once HandleConnection
+	// has created the connection's resp.Writer. db is the logical database
+	// (see db.go) this connection currently has selected; it is only ever
+	// touched by the connection's own goroutine, so it needs no lock.
+	writer     *resp.Writer
+	remoteAddr string
+	db         int
+}
+
+// newClient wraps conn (a real socket, or io.Discard for commands replayed
+// from the AOF at startup) with the per-connection pub/sub and write state.
+func newClient(conn io.Writer) *client {
+	return &client{
+		conn:     conn,
+		outCh:    make(chan []byte, 256),
+		channels: make(map[string]bool),
+		patterns: make(map[string]bool),
+	}
+}
+
+// Write implements io.Writer so a *client can be passed anywhere a plain
+// connection writer is expected; it serializes against pushes delivered by
+// the client's writer goroutine.
+func (c *client) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.Write(p)
+}
+
+// runWriter drains queued pub/sub pushes and writes them to the connection.
+// It exits once outCh is closed (on disconnect).
+func (c *client) runWriter() {
+	for frame := range c.outCh {
+		c.Write(frame)
+	}
+}
+
+// push queues a message frame for delivery without blocking the publisher;
+// if the client's outbound buffer is full the message is dropped rather
+// than stalling PUBLISH for every other subscriber.
+func (c *client) push(frame []byte) {
+	select {
+	case c.outCh <- frame:
+	default:
+	}
+}
+
+func (c *client) inSubscribeMode() bool {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	return len(c.channels) > 0 || len(c.patterns) > 0
+}
+
+// allowedWhileSubscribed restricts a connection with an active subscription,
+// much as Redis does: until it unsubscribes from everything it may only
+// issue further (p)subscribe/(p)unsubscribe commands, PUBSUB, PING or QUIT.
+func allowedWhileSubscribed(command string) bool {
+	switch command {
+	case "SUBSCRIBE", "UNSUBSCRIBE", "PSUBSCRIBE", "PUNSUBSCRIBE", "PING", "QUIT", "PUBSUB":
+		return true
+	default:
+		return false
+	}
+}
+
+func (f *FluxDB) subscribe(c *client, channel string) int {
+	f.pubsubMu.Lock()
+	if f.channels[channel] == nil {
+		f.channels[channel] = make(map[*client]bool)
+	}
+	f.channels[channel][c] = true
+	f.pubsubMu.Unlock()
+
+	c.subMu.Lock()
+	c.channels[channel] = true
+	count := len(c.channels) + len(c.patterns)
+	c.subMu.Unlock()
+
+	return count
+}
+
+func (f *FluxDB) unsubscribe(c *client, channel string) int {
+	f.pubsubMu.Lock()
+	if subs, ok := f.channels[channel]; ok {
+		delete(subs, c)
+		if len(subs) == 0 {
+			delete(f.channels, channel)
+		}
+	}
+	f.pubsubMu.Unlock()
+
+	c.subMu.Lock()
+	delete(c.channels, channel)
+	count := len(c.channels) + len(c.patterns)
+	c.subMu.Unlock()
+
+	return count
+}
+
+func (f *FluxDB) psubscribe(c *client, pattern string) int {
+	f.pubsubMu.Lock()
+	if f.patterns[pattern] == nil {
+		f.patterns[pattern] = make(map[*client]bool)
+	}
+	f.patterns[pattern][c] = true
+	f.pubsubMu.Unlock()
+
+	c.subMu.Lock()
+	c.patterns[pattern] = true
+	count := len(c.channels) + len(c.patterns)
+	c.subMu.Unlock()
+
+	return count
+}
+
+func (f *FluxDB) punsubscribe(c *client, pattern string) int {
+	f.pubsubMu.Lock()
+	if subs, ok := f.patterns[pattern]; ok {
+		delete(subs, c)
+		if len(subs) == 0 {
+			delete(f.patterns, pattern)
+		}
+	}
+	f.pubsubMu.Unlock()
+
+	c.subMu.Lock()
+	delete(c.patterns, pattern)
+	count := len(c.channels) + len(c.patterns)
+	c.subMu.Unlock()
+
+	return count
+}
+
+// unsubscribeAll removes c from every channel and pattern it is a member
+// of; called when a connection closes.
+func (f *FluxDB) unsubscribeAll(c *client) {
+	c.subMu.Lock()
+	channels := make([]string, 0, len(c.channels))
+	for ch := range c.channels {
+		channels = append(channels, ch)
+	}
+	patterns := make([]string, 0, len(c.patterns))
+	for p := range c.patterns {
+		patterns = append(patterns, p)
+	}
+	c.subMu.Unlock()
+
+	for _, ch := range channels {
+		f.unsubscribe(c, ch)
+	}
+	for _, p := range patterns {
+		f.punsubscribe(c, p)
+	}
+}
+
+// publish delivers message to every direct subscriber of channel and to
+// every connection whose pattern matches channel, returning the number of
+// receivers the message was queued for.
+func (f *FluxDB) publish(channel string, message string) int {
+	f.pubsubMu.RLock()
+	defer f.pubsubMu.RUnlock()
+
+	delivered := 0
+
+	if subs, ok := f.channels[channel]; ok {
+		frame := encodePubSubArray("message", channel, message)
+		for c := range subs {
+			c.push(frame)
+			delivered++
+		}
+	}
+
+	for pattern, subs := range f.patterns {
+		if !globMatch(pattern, channel) {
+			continue
+		}
+		frame := encodePubSubArray("pmessage", pattern, message)
+		for c := range subs {
+			c.push(frame)
+			delivered++
+		}
+	}
+
+	return delivered
+}
+
+func (f *FluxDB) pubsubChannels(pattern string) []string {
+	f.pubsubMu.RLock()
+	defer f.pubsubMu.RUnlock()
+
+	result := []string{}
+	for ch := range f.channels {
+		if pattern == "" || pattern == "*" || globMatch(pattern, ch) {
+			result = append(result, ch)
+		}
+	}
+	return result
+}
+
+func (f *FluxDB) pubsubNumSub(channels []string) []string {
+	f.pubsubMu.RLock()
+	defer f.pubsubMu.RUnlock()
+
+	result := make([]string, 0, len(channels)*2)
+	for _, ch := range channels {
+		result = append(result, ch, itoa(len(f.channels[ch])))
+	}
+	return result
+}
+
+func (f *FluxDB) pubsubNumPat() int {
+	f.pubsubMu.RLock()
+	defer f.pubsubMu.RUnlock()
+	return len(f.patterns)
+}
+
+// encodePubSubArray builds the three-element RESP array ["message"|"pmessage", name, payload]
+// used to push a pub/sub message to a subscriber.
+func encodePubSubArray(kind, name, payload string) []byte {
+	var buf bytes.Buffer
+	w := resp.NewWriter(&buf)
+	w.WriteArray(3)
+	w.WriteBulk([]byte(kind))
+	w.WriteBulk([]byte(name))
+	w.WriteBulk([]byte(payload))
+	w.Flush()
+	return buf.Bytes()
+}
+
+// writeSubscribeReply buffers the ["subscribe"|"unsubscribe"|"psubscribe"|"punsubscribe", name, count]
+// confirmation RESP array sent in response to a (un)subscribe call.
+func writeSubscribeReply(w *resp.Writer, kind, name string, count int) {
+	w.WriteArray(3)
+	w.WriteBulk([]byte(kind))
+	w.WriteBulk([]byte(name))
+	w.WriteInt(count)
+}
+
+func itoa(i int) string {
+	return strconv.Itoa(i)
+}
+
+// GLOB MATCHING ------------------------------------------------------------------------------------------------------
+
+// globMatch reports whether name matches pattern using Redis-style glob
+// syntax: '*' matches any run of characters, '?' matches exactly one
+// character, and '[...]' matches any single character in the class
+// (supporting '^' negation and 'a-z' ranges).
+func globMatch(pattern, name string) bool {
+	return globMatchBytes([]byte(pattern), []byte(name))
+}
+
+func globMatchBytes(pattern, name []byte) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			// Collapse consecutive '*' and try every possible split.
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if globMatchBytes(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if len(name) == 0 {
+				return false
+			}
+			pattern = pattern[1:]
+			name = name[1:]
+
+		case '[':
+			if len(name) == 0 {
+				return false
+			}
+			end := indexByte(pattern[1:], ']')
+			if end < 0 {
+				// No closing bracket: treat '[' as a literal.
+				if name[0] != '[' {
+					return false
+				}
+				pattern = pattern[1:]
+				name = name[1:]
+				continue
+			}
+			class := pattern[1 : end+1]
+			if !matchClass(class, name[0]) {
+				return false
+			}
+			pattern = pattern[end+2:]
+			name = name[1:]
+
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if len(name) == 0 || name[0] != pattern[0] {
+				return false
+			}
+			pattern = pattern[1:]
+			name = name[1:]
+
+		default:
+			if len(name) == 0 || name[0] != pattern[0] {
+				return false
+			}
+			pattern = pattern[1:]
+			name = name[1:]
+		}
+	}
+	return len(name) == 0
+}
+
+func matchClass(class []byte, ch byte) bool {
+	negate := false
+	if len(class) > 0 && class[0] == '^' {
+		negate = true
+		class = class[1:]
+	}
+
+	matched := false
+	for i := 0; i < len(class); i++ {
+		if i+2 < len(class) && class[i+1] == '-' {
+			if class[i] <= ch && ch <= class[i+2] {
+				matched = true
+			}
+			i += 2
+			continue
+		}
+		if class[i] == ch {
+			matched = true
+		}
+	}
+
+	return matched != negate
+}
+
+func indexByte(b []byte, c byte) int {
+	for i, v := range b {
+		if v == c {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,58 @@
+package fluxdb
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestPublishDeliversToSubscriber verifies a published message reaches a
+// direct channel subscriber's outbound queue.
+func TestPublishDeliversToSubscriber(t *testing.T) {
+	f := New()
+	c := newClient(&bytes.Buffer{})
+
+	f.subscribe(c, "news")
+
+	if delivered := f.publish("news", "hello"); delivered != 1 {
+		t.Fatalf("publish delivered = %d, want 1", delivered)
+	}
+
+	select {
+	case frame := <-c.outCh:
+		if !bytes.Contains(frame, []byte("hello")) {
+			t.Fatalf("pushed frame %q does not contain payload", frame)
+		}
+	default:
+		t.Fatal("expected a queued frame, got none")
+	}
+}
+
+// TestPublishAfterUnsubscribeAllThenClose reproduces the fix in
+// HandleConnection's cleanup: unsubscribeAll must run before outCh is
+// closed, or a concurrent publish can send on a closed channel and panic.
+func TestPublishAfterUnsubscribeAllThenClose(t *testing.T) {
+	f := New()
+	c := newClient(&bytes.Buffer{})
+	f.subscribe(c, "news")
+
+	f.unsubscribeAll(c)
+	close(c.outCh)
+
+	if delivered := f.publish("news", "hello"); delivered != 0 {
+		t.Fatalf("publish delivered = %d, want 0 after unsubscribeAll", delivered)
+	}
+}
+
+func TestPSubscribeGlobMatch(t *testing.T) {
+	f := New()
+	c := newClient(&bytes.Buffer{})
+
+	f.psubscribe(c, "news.*")
+
+	if delivered := f.publish("news.sports", "score"); delivered != 1 {
+		t.Fatalf("publish delivered = %d, want 1", delivered)
+	}
+	if delivered := f.publish("weather.rain", "storm"); delivered != 0 {
+		t.Fatalf("publish delivered = %d, want 0 for non-matching channel", delivered)
+	}
+}
@@ -0,0 +1,686 @@
+package fluxdb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// COMMAND REGISTRY --------------------------------------------------------------------------------------------------
+//
+// Built-in commands are registered against the FluxDB instance rather than
+// hard-coded into a single switch, so embedders can add, replace or wrap
+// commands (auth, rate limiting, logging, ACLs, ...) without forking. A
+// handler sees only the Conn interface, not the underlying connection or
+// client bookkeeping, and Use composes middleware around every registered
+// handler in the order it was added.
+
+// Command is a single parsed client command handed to a registered Handler.
+type Command struct {
+	Name string
+	Args []string
+}
+
+// Conn is the surface a Handler is allowed to touch: writing a reply and
+// reading connection-scoped metadata. It deliberately hides pub/sub state,
+// the raw socket and buffering details.
+type Conn interface {
+	WriteString(s string)
+	WriteError(s string)
+	WriteInt(n int)
+	WriteBulk(b []byte)
+	WriteArray(n int)
+	RemoteAddr() string
+	Context() context.Context
+
+	// DB returns the logical database index (see db.go) this connection
+	// currently has selected; SelectDB changes it.
+	DB() int
+	SelectDB(n int)
+}
+
+// Handler processes a single command against conn.
+type Handler func(Conn, Command)
+
+// client satisfies Conn by delegating writes to its buffered resp.Writer.
+// Context carries no values today; it exists so handlers can be written
+// against the standard cancellation/deadline idioms as the server grows.
+func (c *client) WriteString(s string) { c.writer.WriteString(s) }
+func (c *client) WriteError(s string)  { c.writer.WriteError(s) }
+func (c *client) WriteInt(n int)       { c.writer.WriteInt(n) }
+func (c *client) WriteBulk(b []byte)   { c.writer.WriteBulk(b) }
+func (c *client) WriteArray(n int)     { c.writer.WriteArray(n) }
+func (c *client) RemoteAddr() string   { return c.remoteAddr }
+func (c *client) Context() context.Context {
+	return context.Background()
+}
+func (c *client) DB() int        { return c.db }
+func (c *client) SelectDB(n int) { c.db = n }
+
+// Register adds or replaces the handler for a command name. Lookups are
+// case-insensitive.
+func (f *FluxDB) Register(name string, handler Handler) {
+	f.handlersMu.Lock()
+	defer f.handlersMu.Unlock()
+	if f.handlers == nil {
+		f.handlers = make(map[string]Handler)
+	}
+	f.handlers[strings.ToUpper(name)] = handler
+}
+
+// Use appends a middleware to the chain wrapped around every registered
+// handler. Middlewares run in the order added: the first call to Use is
+// outermost, so it sees the command first and the reply last.
+func (f *FluxDB) Use(mw func(Handler) Handler) {
+	f.handlersMu.Lock()
+	defer f.handlersMu.Unlock()
+	f.middleware = append(f.middleware, mw)
+}
+
+// lookup returns the handler registered for name with the middleware chain
+// applied, or nil if no handler is registered for it.
+func (f *FluxDB) lookup(name string) Handler {
+	f.handlersMu.RLock()
+	defer f.handlersMu.RUnlock()
+
+	h, ok := f.handlers[name]
+	if !ok {
+		return nil
+	}
+
+	for i := len(f.middleware) - 1; i >= 0; i-- {
+		h = f.middleware[i](h)
+	}
+	return h
+}
+
+// dispatch runs a registered handler for cmd, applying PreHook/PostHook
+// around it, and reports whether a handler was found at all.
+func (f *FluxDB) dispatch(c Conn, cmd Command) bool {
+	handler := f.lookup(cmd.Name)
+	if handler == nil {
+		return false
+	}
+
+	if f.PreHook != nil {
+		f.PreHook(c, cmd)
+	}
+	handler(c, cmd)
+	if f.PostHook != nil {
+		f.PostHook(c, cmd)
+	}
+	return true
+}
+
+// registerBuiltins wires the core Redis-compatible commands into the
+// registry; called once from New(). Commands not yet migrated to the
+// registry (pub/sub, AOF control, ...) remain in processCommand's switch.
+func (f *FluxDB) registerBuiltins() {
+	f.Register("PING", func(c Conn, cmd Command) {
+		if len(cmd.Args) == 0 {
+			c.WriteString("PONG")
+		} else {
+			c.WriteBulk([]byte(cmd.Args[0]))
+		}
+	})
+
+	f.Register("SET", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 2 {
+			c.WriteError("wrong number of arguments for 'set' command")
+			return
+		}
+		result := f.Set(c.DB(), cmd.Args[0], cmd.Args[1])
+		c.WriteString(result)
+	})
+
+	f.Register("GET", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 1 {
+			c.WriteError("wrong number of arguments for 'get' command")
+			return
+		}
+		val, ok, err := f.Get(c.DB(), cmd.Args[0])
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		if !ok {
+			c.WriteBulk(nil)
+			return
+		}
+		c.WriteBulk([]byte(val))
+	})
+
+	f.Register("DEL", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 1 {
+			c.WriteError("wrong number of arguments for 'del' command")
+			return
+		}
+		count := 0
+		for _, key := range cmd.Args {
+			if f.Delete(c.DB(), key) == "true" {
+				count++
+			}
+		}
+		c.WriteInt(count)
+	})
+
+	f.Register("SELECT", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 1 {
+			c.WriteError("wrong number of arguments for 'select' command")
+			return
+		}
+		n, err := strconv.Atoi(cmd.Args[0])
+		if err != nil || n < 0 || n >= f.numDBs {
+			c.WriteError("DB index is out of range")
+			return
+		}
+		c.SelectDB(n)
+		c.WriteString("OK")
+	})
+
+	f.Register("FLUSHDB", func(c Conn, cmd Command) {
+		f.FlushDB(c.DB())
+		c.WriteString("OK")
+	})
+
+	f.Register("FLUSHALL", func(c Conn, cmd Command) {
+		f.FlushAll()
+		f.appendIfMutating(c.DB(), []string{"FLUSHALL"})
+		c.WriteString("OK")
+	})
+
+	f.Register("SWAPDB", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 2 {
+			c.WriteError("wrong number of arguments for 'swapdb' command")
+			return
+		}
+		i, erri := strconv.Atoi(cmd.Args[0])
+		j, errj := strconv.Atoi(cmd.Args[1])
+		if erri != nil || errj != nil {
+			c.WriteError("invalid DB index")
+			return
+		}
+		if err := f.SwapDB(i, j); err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		c.WriteString("OK")
+	})
+
+	f.Register("DBSIZE", func(c Conn, cmd Command) {
+		c.WriteInt(f.DBSize(c.DB()))
+	})
+
+	f.Register("MOVE", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 2 {
+			c.WriteError("wrong number of arguments for 'move' command")
+			return
+		}
+		target, err := strconv.Atoi(cmd.Args[1])
+		if err != nil || target < 0 || target >= f.numDBs {
+			c.WriteError("DB index is out of range")
+			return
+		}
+		if f.Move(c.DB(), cmd.Args[0], target) {
+			c.WriteInt(1)
+		} else {
+			c.WriteInt(0)
+		}
+	})
+
+	f.Register("KEYS", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 1 {
+			c.WriteError("wrong number of arguments for 'keys' command")
+			return
+		}
+		keys := f.Keys(c.DB(), cmd.Args[0])
+		c.WriteArray(len(keys))
+		for _, k := range keys {
+			c.WriteBulk([]byte(k))
+		}
+	})
+
+	f.Register("EXPIRE", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 2 {
+			c.WriteError("wrong number of arguments for 'expire' command")
+			return
+		}
+		seconds, err := strconv.Atoi(cmd.Args[1])
+		if err != nil {
+			c.WriteError("value is not an integer or out of range")
+			return
+		}
+		if f.Expire(c.DB(), cmd.Args[0], time.Duration(seconds)*time.Second) {
+			c.WriteInt(1)
+		} else {
+			c.WriteInt(0)
+		}
+	})
+
+	f.Register("TTL", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 1 {
+			c.WriteError("wrong number of arguments for 'ttl' command")
+			return
+		}
+		c.WriteInt(f.TTL(c.DB(), cmd.Args[0]))
+	})
+
+	f.Register("PERSIST", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 1 {
+			c.WriteError("wrong number of arguments for 'persist' command")
+			return
+		}
+		if f.Persist(c.DB(), cmd.Args[0]) {
+			c.WriteInt(1)
+		} else {
+			c.WriteInt(0)
+		}
+	})
+
+	f.Register("HSET", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 3 || len(cmd.Args)%2 != 1 {
+			c.WriteError("wrong number of arguments for 'hset' command")
+			return
+		}
+		fields, values := pairedArgs(cmd.Args[1:])
+		added, err := f.HSet(c.DB(), cmd.Args[0], fields, values)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		c.WriteInt(added)
+	})
+
+	f.Register("HGET", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 2 {
+			c.WriteError("wrong number of arguments for 'hget' command")
+			return
+		}
+		val, ok, err := f.HGet(c.DB(), cmd.Args[0], cmd.Args[1])
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		if !ok {
+			c.WriteBulk(nil)
+			return
+		}
+		c.WriteBulk([]byte(val))
+	})
+
+	f.Register("HGETALL", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 1 {
+			c.WriteError("wrong number of arguments for 'hgetall' command")
+			return
+		}
+		fields, err := f.HGetAll(c.DB(), cmd.Args[0])
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		c.WriteArray(len(fields) * 2)
+		for field, val := range fields {
+			c.WriteBulk([]byte(field))
+			c.WriteBulk([]byte(val))
+		}
+	})
+
+	f.Register("HDEL", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 2 {
+			c.WriteError("wrong number of arguments for 'hdel' command")
+			return
+		}
+		removed, err := f.HDel(c.DB(), cmd.Args[0], cmd.Args[1:])
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		c.WriteInt(removed)
+	})
+
+	f.Register("LPUSH", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 2 {
+			c.WriteError("wrong number of arguments for 'lpush' command")
+			return
+		}
+		length, err := f.LPush(c.DB(), cmd.Args[0], cmd.Args[1:])
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		c.WriteInt(length)
+	})
+
+	f.Register("RPUSH", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 2 {
+			c.WriteError("wrong number of arguments for 'rpush' command")
+			return
+		}
+		length, err := f.RPush(c.DB(), cmd.Args[0], cmd.Args[1:])
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		c.WriteInt(length)
+	})
+
+	f.Register("LPOP", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 1 {
+			c.WriteError("wrong number of arguments for 'lpop' command")
+			return
+		}
+		val, ok, err := f.LPop(c.DB(), cmd.Args[0])
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		if !ok {
+			c.WriteBulk(nil)
+			return
+		}
+		c.WriteBulk([]byte(val))
+	})
+
+	f.Register("RPOP", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 1 {
+			c.WriteError("wrong number of arguments for 'rpop' command")
+			return
+		}
+		val, ok, err := f.RPop(c.DB(), cmd.Args[0])
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		if !ok {
+			c.WriteBulk(nil)
+			return
+		}
+		c.WriteBulk([]byte(val))
+	})
+
+	f.Register("LRANGE", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 3 {
+			c.WriteError("wrong number of arguments for 'lrange' command")
+			return
+		}
+		start, erri := strconv.Atoi(cmd.Args[1])
+		stop, errj := strconv.Atoi(cmd.Args[2])
+		if erri != nil || errj != nil {
+			c.WriteError("value is not an integer or out of range")
+			return
+		}
+		values, err := f.LRange(c.DB(), cmd.Args[0], start, stop)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		writeStringArrayConn(c, values)
+	})
+
+	f.Register("LLEN", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 1 {
+			c.WriteError("wrong number of arguments for 'llen' command")
+			return
+		}
+		length, err := f.LLen(c.DB(), cmd.Args[0])
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		c.WriteInt(length)
+	})
+
+	f.Register("SADD", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 2 {
+			c.WriteError("wrong number of arguments for 'sadd' command")
+			return
+		}
+		added, err := f.SAdd(c.DB(), cmd.Args[0], cmd.Args[1:])
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		c.WriteInt(added)
+	})
+
+	f.Register("SREM", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 2 {
+			c.WriteError("wrong number of arguments for 'srem' command")
+			return
+		}
+		removed, err := f.SRem(c.DB(), cmd.Args[0], cmd.Args[1:])
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		c.WriteInt(removed)
+	})
+
+	f.Register("SMEMBERS", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 1 {
+			c.WriteError("wrong number of arguments for 'smembers' command")
+			return
+		}
+		members, err := f.SMembers(c.DB(), cmd.Args[0])
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		writeStringArrayConn(c, members)
+	})
+
+	f.Register("SISMEMBER", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 2 {
+			c.WriteError("wrong number of arguments for 'sismember' command")
+			return
+		}
+		ok, err := f.SIsMember(c.DB(), cmd.Args[0], cmd.Args[1])
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		if ok {
+			c.WriteInt(1)
+		} else {
+			c.WriteInt(0)
+		}
+	})
+
+	f.Register("SINTER", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 1 {
+			c.WriteError("wrong number of arguments for 'sinter' command")
+			return
+		}
+		members, err := f.SInter(c.DB(), cmd.Args)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		writeStringArrayConn(c, members)
+	})
+
+	f.Register("SUNION", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 1 {
+			c.WriteError("wrong number of arguments for 'sunion' command")
+			return
+		}
+		members, err := f.SUnion(c.DB(), cmd.Args)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		writeStringArrayConn(c, members)
+	})
+
+	f.Register("ZADD", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 3 || len(cmd.Args)%2 != 1 {
+			c.WriteError("wrong number of arguments for 'zadd' command")
+			return
+		}
+		members, scores, err := parseZAddArgs(cmd.Args[1:])
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		added, err := f.ZAdd(c.DB(), cmd.Args[0], scores, members)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		c.WriteInt(added)
+	})
+
+	f.Register("ZSCORE", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 2 {
+			c.WriteError("wrong number of arguments for 'zscore' command")
+			return
+		}
+		score, ok, err := f.ZScore(c.DB(), cmd.Args[0], cmd.Args[1])
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		if !ok {
+			c.WriteBulk(nil)
+			return
+		}
+		c.WriteBulk([]byte(strconv.FormatFloat(score, 'g', -1, 64)))
+	})
+
+	f.Register("ZRANK", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 2 {
+			c.WriteError("wrong number of arguments for 'zrank' command")
+			return
+		}
+		rank, ok, err := f.ZRank(c.DB(), cmd.Args[0], cmd.Args[1])
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		if !ok {
+			c.WriteBulk(nil)
+			return
+		}
+		c.WriteInt(rank)
+	})
+
+	f.Register("ZRANGE", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 3 {
+			c.WriteError("wrong number of arguments for 'zrange' command")
+			return
+		}
+		start, erri := strconv.Atoi(cmd.Args[1])
+		stop, errj := strconv.Atoi(cmd.Args[2])
+		if erri != nil || errj != nil {
+			c.WriteError("value is not an integer or out of range")
+			return
+		}
+		members, err := f.ZRange(c.DB(), cmd.Args[0], start, stop)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		writeStringArrayConn(c, members)
+	})
+
+	f.Register("ZRANGEBYSCORE", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 3 {
+			c.WriteError("wrong number of arguments for 'zrangebyscore' command")
+			return
+		}
+		min, erri := strconv.ParseFloat(cmd.Args[1], 64)
+		max, errj := strconv.ParseFloat(cmd.Args[2], 64)
+		if erri != nil || errj != nil {
+			c.WriteError("min or max is not a float")
+			return
+		}
+		members, err := f.ZRangeByScore(c.DB(), cmd.Args[0], min, max)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		writeStringArrayConn(c, members)
+	})
+
+	f.Register("HELP", func(c Conn, cmd Command) {
+		c.WriteBulk([]byte(helpText))
+	})
+
+	f.Register("CONFIG", func(c Conn, cmd Command) {
+		if len(cmd.Args) < 1 {
+			c.WriteError("wrong number of arguments for 'config' command")
+			return
+		}
+
+		switch strings.ToUpper(cmd.Args[0]) {
+		case "GET":
+			if len(cmd.Args) < 2 {
+				c.WriteError("wrong number of arguments for 'config get' command")
+				return
+			}
+
+			pattern := cmd.Args[1]
+			result := []string{}
+
+			if pattern == "*" {
+				for k, v := range f.config {
+					result = append(result, k, v)
+				}
+			} else if val := f.GetConfig(pattern); val != "" {
+				result = append(result, pattern, val)
+			}
+
+			c.WriteArray(len(result))
+			for _, s := range result {
+				c.WriteBulk([]byte(s))
+			}
+
+		case "SET":
+			if len(cmd.Args) < 3 {
+				c.WriteError("wrong number of arguments for 'config set' command")
+				return
+			}
+			f.SetConfig(cmd.Args[1], cmd.Args[2])
+			c.WriteString("OK")
+
+		default:
+			c.WriteError("unsupported config operation")
+		}
+	})
+}
+
+// writeStringArrayConn writes a RESP array of bulk strings to a Conn,
+// mirroring writeStringArray for handlers that only see the Conn interface
+// rather than a raw resp.Writer.
+func writeStringArrayConn(c Conn, arr []string) {
+	c.WriteArray(len(arr))
+	for _, s := range arr {
+		c.WriteBulk([]byte(s))
+	}
+}
+
+// pairedArgs splits a flat [field, value, field, value, ...] argument list
+// (as HSET receives it) into parallel field and value slices.
+func pairedArgs(args []string) (fields, values []string) {
+	for i := 0; i+1 < len(args); i += 2 {
+		fields = append(fields, args[i])
+		values = append(values, args[i+1])
+	}
+	return fields, values
+}
+
+// parseZAddArgs splits a flat [score, member, score, member, ...] argument
+// list (as ZADD receives it) into parallel member and score slices.
+func parseZAddArgs(args []string) (members []string, scores []float64, err error) {
+	for i := 0; i+1 < len(args); i += 2 {
+		score, err := strconv.ParseFloat(args[i], 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("value is not a valid float")
+		}
+		scores = append(scores, score)
+		members = append(members, args[i+1])
+	}
+	return members, scores, nil
+}
@@ -0,0 +1,45 @@
+package fluxdb
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/saivenkatram-git/fluxdb/config/resp"
+)
+
+// TestMissingValueEncodesAsRESPNull verifies GET/HGET/LPOP/RPOP/ZSCORE/ZRANK
+// reply with a real RESP null ($-1\r\n) for a missing value rather than a
+// bulk string containing the literal text "nil", which a client like
+// go-redis can't tell apart from an actual value.
+func TestMissingValueEncodesAsRESPNull(t *testing.T) {
+	f := New()
+
+	tests := []struct {
+		name string
+		cmd  Command
+	}{
+		{"GET", Command{Name: "GET", Args: []string{"nokey"}}},
+		{"HGET", Command{Name: "HGET", Args: []string{"nohash", "field"}}},
+		{"LPOP", Command{Name: "LPOP", Args: []string{"nolist"}}},
+		{"RPOP", Command{Name: "RPOP", Args: []string{"nolist"}}},
+		{"ZSCORE", Command{Name: "ZSCORE", Args: []string{"nozset", "member"}}},
+		{"ZRANK", Command{Name: "ZRANK", Args: []string{"nozset", "member"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			c := newClient(&buf)
+			c.writer = resp.NewWriter(&buf)
+
+			if !f.dispatch(c, tt.cmd) {
+				t.Fatalf("%s is not registered", tt.name)
+			}
+			c.writer.Flush()
+
+			if got := buf.String(); got != "$-1\r\n" {
+				t.Fatalf("%s on a missing value wrote %q, want RESP null $-1\\r\\n", tt.name, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package fluxdb
+
+import (
+	"context"
+	"testing"
+)
+
+// fakeConn is a minimal Conn for exercising Register/Use without a real
+// socket or resp.Writer.
+type fakeConn struct {
+	db      int
+	strings []string
+	errors  []string
+	ints    []int
+}
+
+func (c *fakeConn) WriteString(s string)     { c.strings = append(c.strings, s) }
+func (c *fakeConn) WriteError(s string)      { c.errors = append(c.errors, s) }
+func (c *fakeConn) WriteInt(n int)           { c.ints = append(c.ints, n) }
+func (c *fakeConn) WriteBulk(b []byte)       {}
+func (c *fakeConn) WriteArray(n int)         {}
+func (c *fakeConn) RemoteAddr() string       { return "test" }
+func (c *fakeConn) Context() context.Context { return context.Background() }
+func (c *fakeConn) DB() int                  { return c.db }
+func (c *fakeConn) SelectDB(n int)           { c.db = n }
+
+func TestRegisterAndDispatch(t *testing.T) {
+	f := New()
+	f.Register("ECHO", func(c Conn, cmd Command) {
+		c.WriteString(cmd.Args[0])
+	})
+
+	c := &fakeConn{}
+	if !f.dispatch(c, Command{Name: "ECHO", Args: []string{"hi"}}) {
+		t.Fatal("dispatch returned false for a registered command")
+	}
+	if len(c.strings) != 1 || c.strings[0] != "hi" {
+		t.Fatalf("handler output = %v, want [hi]", c.strings)
+	}
+
+	if f.dispatch(c, Command{Name: "NOPE"}) {
+		t.Fatal("dispatch returned true for an unregistered command")
+	}
+}
+
+func TestUseWrapsHandlersInOrder(t *testing.T) {
+	f := New()
+	var order []string
+
+	f.Register("PING", func(c Conn, cmd Command) {
+		order = append(order, "handler")
+		c.WriteString("PONG")
+	})
+	f.Use(func(next Handler) Handler {
+		return func(c Conn, cmd Command) {
+			order = append(order, "outer-before")
+			next(c, cmd)
+			order = append(order, "outer-after")
+		}
+	})
+	f.Use(func(next Handler) Handler {
+		return func(c Conn, cmd Command) {
+			order = append(order, "inner-before")
+			next(c, cmd)
+			order = append(order, "inner-after")
+		}
+	})
+
+	c := &fakeConn{}
+	f.dispatch(c, Command{Name: "PING"})
+
+	want := []string{"outer-before", "inner-before", "handler", "inner-after", "outer-after"}
+	if len(order) != len(want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestPreHookPostHookRunAroundDispatch(t *testing.T) {
+	f := New()
+	f.Register("PING", func(c Conn, cmd Command) { c.WriteString("PONG") })
+
+	var seen []string
+	f.PreHook = func(c Conn, cmd Command) { seen = append(seen, "pre") }
+	f.PostHook = func(c Conn, cmd Command) { seen = append(seen, "post") }
+
+	f.dispatch(&fakeConn{}, Command{Name: "PING"})
+
+	if len(seen) != 2 || seen[0] != "pre" || seen[1] != "post" {
+		t.Fatalf("hooks fired = %v, want [pre post]", seen)
+	}
+}
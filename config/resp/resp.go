@@ -0,0 +1,318 @@
+// Package resp implements a small, allocation-conscious RESP (REdis
+// Serialization Protocol) reader and writer, modeled after the API shape
+// popularized by community redcon-style Redis protocol libraries.
+//
+// Reader.ReadCommand drains every command already sitting in its internal
+// buffer before issuing another Read on the underlying connection, so a
+// client that pipelines N commands in a single packet costs one syscall
+// instead of N. Writer buffers replies and is flushed explicitly by the
+// caller, so a pipelined batch of replies costs one Write instead of N.
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// errIncomplete signals that buf does not yet contain a full command and
+// more data must be read before parsing can continue. It never escapes
+// this package.
+var errIncomplete = errors.New("resp: incomplete command")
+
+// ErrProtocol is returned when the input violates the RESP grammar.
+var ErrProtocol = errors.New("resp: protocol error")
+
+const minBufSize = 4096
+
+// Command is a single parsed client command. Args and Raw reference the
+// Reader's internal buffer and are only valid until the next call to
+// ReadCommand; callers that need to retain a Command past that point must
+// copy it.
+type Command struct {
+	Raw  []byte
+	Args [][]byte
+}
+
+// Reader parses a stream of RESP commands (arrays of bulk strings, or
+// inline commands) out of an io.Reader.
+type Reader struct {
+	rd  io.Reader
+	buf []byte
+	pos int // start of unparsed data
+	end int // end of valid data
+}
+
+// NewReader returns a Reader that reads RESP commands from rd.
+func NewReader(rd io.Reader) *Reader {
+	return &Reader{rd: rd, buf: make([]byte, minBufSize)}
+}
+
+// Buffered reports how many unparsed bytes are already sitting in memory.
+// A non-zero value after ReadCommand means at least the start of another
+// pipelined command has already arrived and no further syscall is needed
+// to make progress on it.
+func (r *Reader) Buffered() int {
+	return r.end - r.pos
+}
+
+// ReadCommand returns the next command in the stream, reading from the
+// underlying connection only when the buffered data does not yet contain
+// a complete command.
+func (r *Reader) ReadCommand() (Command, error) {
+	for {
+		cmd, n, err := parseCommand(r.buf[r.pos:r.end])
+		if err == errIncomplete {
+			if err := r.fill(); err != nil {
+				return Command{}, err
+			}
+			continue
+		}
+		if err != nil {
+			return Command{}, err
+		}
+
+		cmd.Raw = r.buf[r.pos : r.pos+n]
+		r.pos += n
+		return cmd, nil
+	}
+}
+
+// fill reads more data from the connection, growing or compacting the
+// internal buffer as needed.
+func (r *Reader) fill() error {
+	if r.pos > 0 {
+		copy(r.buf, r.buf[r.pos:r.end])
+		r.end -= r.pos
+		r.pos = 0
+	}
+
+	if r.end == len(r.buf) {
+		grown := make([]byte, len(r.buf)*2)
+		copy(grown, r.buf[:r.end])
+		r.buf = grown
+	}
+
+	n, err := r.rd.Read(r.buf[r.end:])
+	r.end += n
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return io.ErrNoProgress
+	}
+	return nil
+}
+
+// parseCommand attempts to parse a single command from the front of b. It
+// returns the number of bytes consumed on success, or errIncomplete if b
+// does not yet hold a full command.
+func parseCommand(b []byte) (Command, int, error) {
+	if len(b) == 0 {
+		return Command{}, 0, errIncomplete
+	}
+
+	if b[0] != '*' {
+		return parseInline(b)
+	}
+	return parseArray(b)
+}
+
+// parseArray parses a RESP array of bulk strings, e.g. "*2\r\n$3\r\nGET\r\n$3\r\nfoo\r\n".
+func parseArray(b []byte) (Command, int, error) {
+	line, lineLen, ok := readLine(b)
+	if !ok {
+		return Command{}, 0, errIncomplete
+	}
+
+	count, ok := parseInt(line[1:])
+	if !ok {
+		return Command{}, 0, ErrProtocol
+	}
+
+	pos := lineLen
+	if count <= 0 {
+		return Command{}, pos, nil
+	}
+
+	args := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		if pos >= len(b) || b[pos] != '$' {
+			if pos >= len(b) {
+				return Command{}, 0, errIncomplete
+			}
+			return Command{}, 0, ErrProtocol
+		}
+
+		lenLine, lenLineLen, ok := readLine(b[pos:])
+		if !ok {
+			return Command{}, 0, errIncomplete
+		}
+
+		strLen, ok := parseInt(lenLine[1:])
+		if !ok {
+			return Command{}, 0, ErrProtocol
+		}
+
+		pos += lenLineLen
+		if strLen < 0 {
+			args = append(args, nil)
+			continue
+		}
+
+		need := strLen + 2 // payload + \r\n
+		if pos+need > len(b) {
+			return Command{}, 0, errIncomplete
+		}
+
+		args = append(args, b[pos:pos+strLen])
+		pos += need
+	}
+
+	return Command{Args: args}, pos, nil
+}
+
+// parseInline parses a legacy inline command: a single line of
+// space-separated tokens terminated by \r\n or \n, e.g. "PING\r\n".
+func parseInline(b []byte) (Command, int, error) {
+	line, lineLen, ok := readLine(b)
+	if !ok {
+		return Command{}, 0, errIncomplete
+	}
+
+	var args [][]byte
+	start := -1
+	for i := 0; i <= len(line); i++ {
+		atSpace := i == len(line) || line[i] == ' '
+		if !atSpace && start < 0 {
+			start = i
+		} else if atSpace && start >= 0 {
+			args = append(args, line[start:i])
+			start = -1
+		}
+	}
+
+	return Command{Args: args}, lineLen, nil
+}
+
+// readLine returns the content of the next \r\n (or \n) terminated line
+// within b, excluding the terminator, along with the total number of
+// bytes it and its terminator occupy.
+func readLine(b []byte) ([]byte, int, bool) {
+	idx := bytes.IndexByte(b, '\n')
+	if idx < 0 {
+		return nil, 0, false
+	}
+	end := idx
+	if end > 0 && b[end-1] == '\r' {
+		end--
+	}
+	return b[:end], idx + 1, true
+}
+
+// parseInt parses a (possibly negative) base-10 integer from b without the
+// string conversion / allocation strconv.Atoi would require.
+func parseInt(b []byte) (int, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+
+	neg := false
+	if b[0] == '-' {
+		neg = true
+		b = b[1:]
+		if len(b) == 0 {
+			return 0, false
+		}
+	}
+
+	n := 0
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+
+	if neg {
+		n = -n
+	}
+	return n, true
+}
+
+// Writer buffers RESP replies so a batch of pipelined responses can be
+// flushed to the connection in a single Write call.
+type Writer struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewWriter returns a Writer that flushes to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, buf: make([]byte, 0, minBufSize)}
+}
+
+// WriteString buffers a RESP simple string reply, e.g. +OK\r\n.
+func (w *Writer) WriteString(s string) {
+	w.buf = append(w.buf, '+')
+	w.buf = append(w.buf, s...)
+	w.buf = append(w.buf, '\r', '\n')
+}
+
+// WriteError buffers a RESP error reply, e.g. -ERR foo\r\n.
+func (w *Writer) WriteError(s string) {
+	w.buf = append(w.buf, '-')
+	w.buf = append(w.buf, s...)
+	w.buf = append(w.buf, '\r', '\n')
+}
+
+// WriteInt buffers a RESP integer reply, e.g. :1000\r\n.
+func (w *Writer) WriteInt(n int) {
+	w.buf = append(w.buf, ':')
+	w.buf = strconv.AppendInt(w.buf, int64(n), 10)
+	w.buf = append(w.buf, '\r', '\n')
+}
+
+// WriteBulk buffers a RESP bulk string reply. A nil b buffers a null bulk
+// string ($-1\r\n).
+func (w *Writer) WriteBulk(b []byte) {
+	if b == nil {
+		w.WriteNull()
+		return
+	}
+	w.buf = append(w.buf, '$')
+	w.buf = strconv.AppendInt(w.buf, int64(len(b)), 10)
+	w.buf = append(w.buf, '\r', '\n')
+	w.buf = append(w.buf, b...)
+	w.buf = append(w.buf, '\r', '\n')
+}
+
+// WriteArray buffers a RESP array header for n elements; the caller must
+// follow it with exactly n further Write* calls for the elements.
+func (w *Writer) WriteArray(n int) {
+	w.buf = append(w.buf, '*')
+	w.buf = strconv.AppendInt(w.buf, int64(n), 10)
+	w.buf = append(w.buf, '\r', '\n')
+}
+
+// WriteNull buffers a null bulk string reply ($-1\r\n).
+func (w *Writer) WriteNull() {
+	w.buf = append(w.buf, '$', '-', '1', '\r', '\n')
+}
+
+// Buffered reports how many bytes are queued and not yet flushed.
+func (w *Writer) Buffered() int {
+	return len(w.buf)
+}
+
+// Flush writes any buffered replies to the underlying writer in a single
+// call and resets the buffer.
+func (w *Writer) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := w.w.Write(w.buf)
+	w.buf = w.buf[:0]
+	return err
+}
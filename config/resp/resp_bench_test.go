@@ -0,0 +1,95 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// pipelineCount is the number of pipelined SET commands buildPipeline
+// produces, the shape a real client sends when pipelining is enabled.
+const pipelineCount = 8
+
+func buildPipeline() []byte {
+	var buf bytes.Buffer
+	for i := 0; i < pipelineCount; i++ {
+		fmt.Fprintf(&buf, "*3\r\n$3\r\nSET\r\n$4\r\nkey%d\r\n$5\r\nvalue\r\n", i%10)
+	}
+	return buf.Bytes()
+}
+
+// BenchmarkReader_ReadCommand exercises the pipelining path: one buffer
+// full of commands should cost a single Read from the underlying source.
+func BenchmarkReader_ReadCommand(b *testing.B) {
+	data := buildPipeline()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r := NewReader(bytes.NewReader(data))
+		for j := 0; j < pipelineCount; j++ {
+			if _, err := r.ReadCommand(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkLegacyParse reproduces the allocation pattern of the original
+// bufio.Reader + strings.Fields based parser for comparison.
+func BenchmarkLegacyParse(b *testing.B) {
+	data := buildPipeline()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		reader := bufio.NewReader(bytes.NewReader(data))
+		for j := 0; j < pipelineCount; j++ {
+			line, _ := reader.ReadString('\n')
+			line = strings.TrimSpace(line)
+			count, _ := strconv.Atoi(line[1:])
+			args := make([]string, 0, count)
+			for k := 0; k < count; k++ {
+				reader.ReadString('\n') // "$N"
+				val, _ := reader.ReadString('\n')
+				args = append(args, strings.TrimSpace(val))
+			}
+			_ = strings.Fields(strings.Join(args, " "))
+		}
+	}
+}
+
+// BenchmarkWriter_Flush exercises buffering a batch of replies and
+// flushing them once, versus writing each with fmt.Fprintf.
+func BenchmarkWriter_Flush(b *testing.B) {
+	var sink bytes.Buffer
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		sink.Reset()
+		w := NewWriter(&sink)
+		for j := 0; j < pipelineCount; j++ {
+			w.WriteString("OK")
+		}
+		if err := w.Flush(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLegacyWrite(b *testing.B) {
+	var sink bytes.Buffer
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		sink.Reset()
+		for j := 0; j < pipelineCount; j++ {
+			fmt.Fprintf(&sink, "+%s\r\n", "OK")
+		}
+	}
+}
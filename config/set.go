@@ -0,0 +1,187 @@
+package fluxdb
+
+// SETS ---------------------------------------------------------------------------------------------------------------
+//
+// A set entry stores a map[string]struct{}, the usual Go idiom for a set.
+// SINTER/SUNION copy each key's set under its own acquisition of the
+// keyspace lock, one key at a time, rather than holding the lock across the
+// whole operation, so a write to one of the keys between two copies is
+// visible in the result for some keys and not others.
+
+// setAt returns the set entry at key, creating an empty one if key is
+// absent. Callers must hold ks.mu for writing.
+func (ks *keyspace) setAt(key string) (*entry, error) {
+	e, exists := ks.lookup(key)
+	if !exists {
+		e = &entry{typ: typeSet, set: make(map[string]struct{})}
+		ks.data[key] = e
+		return e, nil
+	}
+	if e.typ != typeSet {
+		return nil, errWrongType
+	}
+	return e, nil
+}
+
+// SAdd adds members to the set at key, creating the set if it doesn't
+// exist, and returns how many members were newly added.
+func (f *FluxDB) SAdd(db int, key string, members []string) (int, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, err := ks.setAt(key)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, m := range members {
+		if _, exists := e.set[m]; !exists {
+			e.set[m] = struct{}{}
+			added++
+		}
+	}
+	f.appendIfMutating(db, append([]string{"SADD", key}, members...))
+	return added, nil
+}
+
+// SRem removes members from the set at key and returns how many were
+// actually present. The key itself is removed once its last member is gone.
+func (f *FluxDB) SRem(db int, key string, members []string) (int, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, exists := ks.lookup(key)
+	if !exists {
+		return 0, nil
+	}
+	if e.typ != typeSet {
+		return 0, errWrongType
+	}
+
+	removed := 0
+	for _, m := range members {
+		if _, ok := e.set[m]; ok {
+			delete(e.set, m)
+			removed++
+		}
+	}
+	if len(e.set) == 0 {
+		delete(ks.data, key)
+	}
+	f.appendIfMutating(db, append([]string{"SREM", key}, members...))
+	return removed, nil
+}
+
+// SMembers returns every member of the set at key.
+func (f *FluxDB) SMembers(db int, key string) ([]string, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, exists := ks.lookup(key)
+	if !exists {
+		return []string{}, nil
+	}
+	if e.typ != typeSet {
+		return nil, errWrongType
+	}
+
+	out := make([]string, 0, len(e.set))
+	for m := range e.set {
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// SIsMember reports whether member belongs to the set at key.
+func (f *FluxDB) SIsMember(db int, key, member string) (bool, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, exists := ks.lookup(key)
+	if !exists {
+		return false, nil
+	}
+	if e.typ != typeSet {
+		return false, errWrongType
+	}
+	_, ok := e.set[member]
+	return ok, nil
+}
+
+// setSnapshot returns a copy of the set stored at key (empty if key is
+// absent), and an error if it holds a non-set value.
+func (f *FluxDB) setSnapshot(db int, key string) (map[string]struct{}, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, exists := ks.lookup(key)
+	if !exists {
+		return map[string]struct{}{}, nil
+	}
+	if e.typ != typeSet {
+		return nil, errWrongType
+	}
+
+	out := make(map[string]struct{}, len(e.set))
+	for m := range e.set {
+		out[m] = struct{}{}
+	}
+	return out, nil
+}
+
+// SInter returns the intersection of the sets at keys.
+func (f *FluxDB) SInter(db int, keys []string) ([]string, error) {
+	if len(keys) == 0 {
+		return []string{}, nil
+	}
+
+	result, err := f.setSnapshot(db, keys[0])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys[1:] {
+		next, err := f.setSnapshot(db, key)
+		if err != nil {
+			return nil, err
+		}
+		for m := range result {
+			if _, ok := next[m]; !ok {
+				delete(result, m)
+			}
+		}
+	}
+
+	out := make([]string, 0, len(result))
+	for m := range result {
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// SUnion returns the union of the sets at keys.
+func (f *FluxDB) SUnion(db int, keys []string) ([]string, error) {
+	result := make(map[string]struct{})
+
+	for _, key := range keys {
+		next, err := f.setSnapshot(db, key)
+		if err != nil {
+			return nil, err
+		}
+		for m := range next {
+			result[m] = struct{}{}
+		}
+	}
+
+	out := make([]string, 0, len(result))
+	for m := range result {
+		out = append(out, m)
+	}
+	return out, nil
+}
@@ -0,0 +1,306 @@
+package fluxdb
+
+import (
+	"math/rand"
+	"strconv"
+)
+
+// SORTED SETS --------------------------------------------------------------------------------------------------------
+//
+// A zset entry pairs a map[string]float64 (dict, for O(1) ZSCORE lookups)
+// with a skiplist ordered by (score, member) - the same structure Redis
+// uses internally - so ZRANGE/ZRANGEBYSCORE/ZRANK can walk members in order
+// without sorting on every call.
+
+const (
+	zskiplistMaxLevel = 32
+	zskiplistP        = 0.25
+)
+
+// zskiplistNode is one member/score pair in the skiplist, linked at every
+// level up to len(level).
+type zskiplistNode struct {
+	member string
+	score  float64
+	level  []struct{ forward *zskiplistNode }
+}
+
+// zskiplist is an ordered singly-linked skiplist keyed by (score, member).
+type zskiplist struct {
+	header *zskiplistNode
+	level  int
+	length int
+}
+
+func newZSkiplist() *zskiplist {
+	header := &zskiplistNode{level: make([]struct{ forward *zskiplistNode }, zskiplistMaxLevel)}
+	return &zskiplist{header: header, level: 1}
+}
+
+// less orders (score, member) pairs the way Redis sorted sets do: by score,
+// then lexicographically by member to break ties.
+func less(score1 float64, member1 string, score2 float64, member2 string) bool {
+	if score1 != score2 {
+		return score1 < score2
+	}
+	return member1 < member2
+}
+
+func randomLevel() int {
+	level := 1
+	for level < zskiplistMaxLevel && rand.Float64() < zskiplistP {
+		level++
+	}
+	return level
+}
+
+func (zsl *zskiplist) insert(score float64, member string) {
+	update := make([]*zskiplistNode, zskiplistMaxLevel)
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && less(x.level[i].forward.score, x.level[i].forward.member, score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > zsl.level {
+		for i := zsl.level; i < level; i++ {
+			update[i] = zsl.header
+		}
+		zsl.level = level
+	}
+
+	node := &zskiplistNode{member: member, score: score, level: make([]struct{ forward *zskiplistNode }, level)}
+	for i := 0; i < level; i++ {
+		node.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = node
+	}
+	zsl.length++
+}
+
+func (zsl *zskiplist) delete(score float64, member string) {
+	update := make([]*zskiplistNode, zskiplistMaxLevel)
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && less(x.level[i].forward.score, x.level[i].forward.member, score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	target := x.level[0].forward
+	if target == nil || target.score != score || target.member != member {
+		return
+	}
+
+	for i := 0; i < zsl.level; i++ {
+		if update[i].level[i].forward != target {
+			continue
+		}
+		update[i].level[i].forward = target.level[i].forward
+	}
+	for zsl.level > 1 && zsl.header.level[zsl.level-1].forward == nil {
+		zsl.level--
+	}
+	zsl.length--
+}
+
+// rank returns the 0-based position of member in ascending (score, member)
+// order. Walking level 0 is O(n), but it keeps the skiplist itself simple
+// (no span bookkeeping). rangeByRank and rangeByScore likewise walk level 0
+// from the head rather than descending the upper levels.
+func (zsl *zskiplist) rank(member string) (int, bool) {
+	i := 0
+	for x := zsl.header.level[0].forward; x != nil; x = x.level[0].forward {
+		if x.member == member {
+			return i, true
+		}
+		i++
+	}
+	return 0, false
+}
+
+// rangeByRank returns the members ranked [start, stop], inclusive.
+func (zsl *zskiplist) rangeByRank(start, stop int) []string {
+	if start > stop {
+		return []string{}
+	}
+	out := []string{}
+	i := 0
+	for x := zsl.header.level[0].forward; x != nil; x = x.level[0].forward {
+		if i > stop {
+			break
+		}
+		if i >= start {
+			out = append(out, x.member)
+		}
+		i++
+	}
+	return out
+}
+
+// rangeByScore returns every member with min <= score <= max, in ascending
+// order.
+func (zsl *zskiplist) rangeByScore(min, max float64) []string {
+	out := []string{}
+	for x := zsl.header.level[0].forward; x != nil; x = x.level[0].forward {
+		if x.score > max {
+			break
+		}
+		if x.score >= min {
+			out = append(out, x.member)
+		}
+	}
+	return out
+}
+
+// zset is the value stored by a typeZSet entry.
+type zset struct {
+	dict map[string]float64
+	zsl  *zskiplist
+}
+
+func newZSet() *zset {
+	return &zset{dict: make(map[string]float64), zsl: newZSkiplist()}
+}
+
+// add sets member's score, reporting whether member is newly added.
+func (z *zset) add(member string, score float64) bool {
+	if old, exists := z.dict[member]; exists {
+		if old != score {
+			z.zsl.delete(old, member)
+			z.zsl.insert(score, member)
+		}
+		z.dict[member] = score
+		return false
+	}
+	z.zsl.insert(score, member)
+	z.dict[member] = score
+	return true
+}
+
+// zsetAt returns the zset entry at key, creating an empty one if key is
+// absent. Callers must hold ks.mu for writing.
+func (ks *keyspace) zsetAt(key string) (*entry, error) {
+	e, exists := ks.lookup(key)
+	if !exists {
+		e = &entry{typ: typeZSet, zset: newZSet()}
+		ks.data[key] = e
+		return e, nil
+	}
+	if e.typ != typeZSet {
+		return nil, errWrongType
+	}
+	return e, nil
+}
+
+// ZAdd adds or updates members[i] with scores[i] in the sorted set at key,
+// creating it if it doesn't exist, and returns how many members were newly
+// added.
+func (f *FluxDB) ZAdd(db int, key string, scores []float64, members []string) (int, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, err := ks.zsetAt(key)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	logArgs := make([]string, 0, 1+2*len(members))
+	logArgs = append(logArgs, key)
+	for i, member := range members {
+		if e.zset.add(member, scores[i]) {
+			added++
+		}
+		logArgs = append(logArgs, strconv.FormatFloat(scores[i], 'g', -1, 64), member)
+	}
+	f.appendIfMutating(db, append([]string{"ZADD"}, logArgs...))
+	return added, nil
+}
+
+// ZScore returns the score of member in the sorted set at key.
+func (f *FluxDB) ZScore(db int, key, member string) (float64, bool, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, exists := ks.lookup(key)
+	if !exists {
+		return 0, false, nil
+	}
+	if e.typ != typeZSet {
+		return 0, false, errWrongType
+	}
+	score, ok := e.zset.dict[member]
+	return score, ok, nil
+}
+
+// ZRank returns the 0-based rank of member in the sorted set at key,
+// ordered by ascending score.
+func (f *FluxDB) ZRank(db int, key, member string) (int, bool, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, exists := ks.lookup(key)
+	if !exists {
+		return 0, false, nil
+	}
+	if e.typ != typeZSet {
+		return 0, false, errWrongType
+	}
+	rank, ok := e.zset.zsl.rank(member)
+	return rank, ok, nil
+}
+
+// ZRange returns the members ranked [start, stop] in the sorted set at key,
+// interpreting negative indexes as counting from the highest rank (-1 is
+// the last), same as Redis's ZRANGE.
+func (f *FluxDB) ZRange(db int, key string, start, stop int) ([]string, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, exists := ks.lookup(key)
+	if !exists {
+		return []string{}, nil
+	}
+	if e.typ != typeZSet {
+		return nil, errWrongType
+	}
+
+	n := e.zset.zsl.length
+	start = resolveListIndex(start, n)
+	stop = resolveListIndex(stop, n)
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop || n == 0 {
+		return []string{}, nil
+	}
+	return e.zset.zsl.rangeByRank(start, stop), nil
+}
+
+// ZRangeByScore returns every member of the sorted set at key with a score
+// between min and max, inclusive, in ascending score order.
+func (f *FluxDB) ZRangeByScore(db int, key string, min, max float64) ([]string, error) {
+	ks := f.keyspaceAt(db)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, exists := ks.lookup(key)
+	if !exists {
+		return []string{}, nil
+	}
+	if e.typ != typeZSet {
+		return nil, errWrongType
+	}
+	return e.zset.zsl.rangeByScore(min, max), nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net"
@@ -9,8 +10,29 @@ import (
 )
 
 func main() {
+	appendonly := flag.String("appendonly", "", "enable the append-only file (yes/no)")
+	appendfilename := flag.String("appendfilename", "", "append-only filename")
+	appendfsync := flag.String("appendfsync", "", "append-only fsync policy (always/everysec/no)")
+	dir := flag.String("dir", "", "working directory for the append-only file")
+	databases := flag.Int("databases", 0, "number of logical databases (default 16)")
+	flag.Parse()
+
+	var opts []fluxdb.Option
+	if *databases > 0 {
+		opts = append(opts, fluxdb.WithDatabases(*databases))
+	}
+	for key, value := range map[string]string{
+		"appendonly":     *appendonly,
+		"appendfilename": *appendfilename,
+		"appendfsync":    *appendfsync,
+		"dir":            *dir,
+	} {
+		if value != "" {
+			opts = append(opts, fluxdb.WithConfig(key, value))
+		}
+	}
 
-	fluxdb := fluxdb.New()
+	fluxdb := fluxdb.New(opts...)
 
 	port := fluxdb.GetConfig("port")
 	bind := fluxdb.GetConfig("bind")